@@ -7,31 +7,69 @@ import (
 	"crud/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
 func SetUpApi(cfg *config.Config, r *gin.Engine, storage storage.StorageI) {
 
 	handlerV1 := handler.NewHandlerV1(cfg, storage)
 
-	r.POST("/category", handlerV1.CreateCategory)
+	superAdmin := handlerV1.AuthMiddleware(cfg.SuperAdmin)
+	anyUser := handlerV1.AuthMiddleware(cfg.SuperAdmin, cfg.Client)
+	idempotent := handlerV1.Idempotency()
+
+	r.GET("/healthz", handlerV1.Healthz)
+	r.GET("/readyz", handlerV1.Readyz)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	r.POST("/register", handlerV1.Register)
+	r.POST("/login", handlerV1.Login)
+	r.POST("/refresh", handlerV1.RefreshToken)
+
+	r.POST("/category", superAdmin, handlerV1.CreateCategory)
+	r.POST("/category/import", superAdmin, handlerV1.ImportCategory)
 	r.GET("/category/:id", handlerV1.GetCategoryById)
 	r.GET("/category", handlerV1.GetCategoryList)
-	r.PUT("/category/:id", handlerV1.UpdateCategory)
-	r.DELETE("/category/:id", handlerV1.DeleteCategory)
+	r.GET("/category/tree", handlerV1.GetCategoryTree)
+	r.GET("/category/:id/tree", handlerV1.GetCategorySubtree)
+	r.GET("/category/:id/path", handlerV1.GetCategoryPath)
+	r.PUT("/category/:id", superAdmin, handlerV1.UpdateCategory)
+	r.PATCH("/category/:id", superAdmin, handlerV1.PatchCategory)
+	r.DELETE("/category/:id", superAdmin, handlerV1.DeleteCategory)
 
-	r.POST("/product", handlerV1.CreateProduct)
+	r.POST("/product", superAdmin, idempotent, handlerV1.CreateProduct)
+	r.POST("/product/import", superAdmin, handlerV1.ImportProduct)
 	r.GET("/product/:id", handlerV1.GetProductById)
 	r.GET("/product", handlerV1.GetProductList)
-	r.PUT("/product/:id", handlerV1.UpdateProduct)
-	r.DELETE("/product/:id", handlerV1.DeleteProduct)
-
-	r.POST("/order", handlerV1.CreateOrder)
-	r.GET("/order/:id", handlerV1.GetOrderById)
-	r.GET("/order", handlerV1.GetOrderList)
-	r.PUT("/order/:id", handlerV1.UpdateOrder)
-	r.DELETE("/order/:id", handlerV1.DeleteOrder)
+	r.GET("/product/export", superAdmin, handlerV1.ExportProduct)
+	r.PUT("/product/:id", superAdmin, handlerV1.UpdateProduct)
+	r.PATCH("/product/:id", superAdmin, handlerV1.PatchProduct)
+	r.DELETE("/product/:id", superAdmin, handlerV1.DeleteProduct)
+	r.POST("/product/:id/restock", superAdmin, handlerV1.RestockProduct)
+	r.GET("/product/:id/movements", superAdmin, handlerV1.GetProductMovements)
+	r.POST("/product/:id/prices", superAdmin, handlerV1.ScheduleProductPrice)
+	r.GET("/product/:id/prices", superAdmin, handlerV1.GetProductPrices)
+
+	r.POST("/order", anyUser, idempotent, handlerV1.CreateOrder)
+	r.GET("/order/:id", anyUser, handlerV1.GetOrderById)
+	r.GET("/order", anyUser, handlerV1.GetOrderList)
+	r.GET("/order/export", anyUser, handlerV1.ExportOrder)
+	r.PUT("/order/:id", superAdmin, handlerV1.UpdateOrder)
+	r.PATCH("/order/:id", superAdmin, handlerV1.PatchOrder)
+	r.DELETE("/order/:id", superAdmin, handlerV1.DeleteOrder)
+	r.POST("/order/:id/transition", superAdmin, handlerV1.TransitionOrder)
+
+	r.POST("/webhook", anyUser, handlerV1.CreateWebhook)
+	r.GET("/webhook/:id", anyUser, handlerV1.GetWebhookById)
+	r.GET("/webhook", anyUser, handlerV1.GetWebhookList)
+	r.PUT("/webhook/:id", anyUser, handlerV1.UpdateWebhook)
+	r.DELETE("/webhook/:id", anyUser, handlerV1.DeleteWebhook)
+	r.GET("/webhook/:id/deliveries", anyUser, handlerV1.GetWebhookDeliveries)
 
 	url := ginSwagger.URL("swagger/doc.json") // The url pointing to API definition
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
@@ -0,0 +1,506 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"crud/api"
+	"crud/config"
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/storage"
+	"crud/storage/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *config.Config, storage.StorageI) {
+
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		AuthSecretKey:  "0123456789abcdef0123456789abcdef",
+		SuperAdmin:     "SUPER_ADMIN",
+		Client:         "CLIENT",
+		IdempotencyTTL: time.Hour,
+		ImportMaxRows:  100,
+	}
+
+	store := memory.NewMemory()
+
+	r := gin.New()
+	api.SetUpApi(cfg, r, store)
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return server, cfg, store
+}
+
+func accessToken(t *testing.T, cfg *config.Config, id, role string) string {
+
+	token, err := helper.GenerateJWT(map[string]interface{}{
+		"id":   id,
+		"role": role,
+		"type": "access",
+	}, time.Hour, cfg.AuthSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+type response struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// step is one request of a scenario. path and body are built when the step
+// runs, so they can use the ids earlier steps saved; check reads the data of
+// the response.
+type step struct {
+	name   string
+	method string
+	path   func() string
+	token  *string
+	body   func() string
+	status int
+	check  func(t *testing.T, data json.RawMessage)
+}
+
+func run(t *testing.T, server *httptest.Server, steps []step) {
+
+	for _, s := range steps {
+		var body io.Reader
+		if s.body != nil {
+			body = bytes.NewBufferString(s.body())
+		}
+
+		req, err := http.NewRequest(s.method, server.URL+s.path(), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		if s.token != nil {
+			req.Header.Set("Authorization", "Bearer "+*s.token)
+		}
+
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != s.status {
+			t.Fatalf("%s: %s %s answered %d, want %d: %s", s.name, s.method, s.path(), resp.StatusCode, s.status, raw)
+		}
+
+		if s.check == nil || len(raw) == 0 {
+			continue
+		}
+
+		var r response
+		err = json.Unmarshal(raw, &r)
+		if err != nil {
+			t.Fatalf("%s: %v: %s", s.name, err, raw)
+		}
+
+		s.check(t, r.Data)
+	}
+}
+
+func path(p string) func() string {
+	return func() string { return p }
+}
+
+// decode unmarshals data into dest, failing the test when it cannot.
+func decode(t *testing.T, data json.RawMessage, dest interface{}) {
+	if err := json.Unmarshal(data, dest); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+}
+
+// saveID keeps the id of the created resource in id.
+func saveID(id *string) func(t *testing.T, data json.RawMessage) {
+	return func(t *testing.T, data json.RawMessage) {
+		var resp struct {
+			Id string `json:"id"`
+		}
+		decode(t, data, &resp)
+
+		if resp.Id == "" {
+			t.Fatalf("no id in %s", data)
+		}
+
+		*id = resp.Id
+	}
+}
+
+func TestAPI(t *testing.T) {
+
+	server, cfg, store := newTestServer(t)
+
+	var (
+		admin  = accessToken(t, cfg, "00000000-0000-0000-0000-000000000001", cfg.SuperAdmin)
+		client string
+		other  = accessToken(t, cfg, "00000000-0000-0000-0000-000000000002", cfg.Client)
+
+		rootID, childID, productID, orderID string
+
+		productPrices int
+	)
+
+	// prices reads the price history from the storage, the endpoint answers
+	// 404 once the product is deleted
+	prices := func(t *testing.T) int {
+		resp, err := store.Product().GetPrices(context.Background(), &models.ProductPrimarKey{Id: productID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return len(resp.Prices)
+	}
+
+	run(t, server, []step{
+		{
+			name:   "register a client",
+			method: http.MethodPost,
+			path:   path("/register"),
+			body:   func() string { return `{"login":"client","password":"secret123"}` },
+			status: http.StatusCreated,
+		},
+		{
+			name:   "log the client in",
+			method: http.MethodPost,
+			path:   path("/login"),
+			body:   func() string { return `{"login":"client","password":"secret123"}` },
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var resp struct {
+					AccessToken string `json:"access_token"`
+				}
+				decode(t, data, &resp)
+				client = resp.AccessToken
+			},
+		},
+		{
+			name:   "create a category without a token",
+			method: http.MethodPost,
+			path:   path("/category"),
+			body:   func() string { return `{"name":"electronics"}` },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "create a category as a client",
+			method: http.MethodPost,
+			path:   path("/category"),
+			token:  &client,
+			body:   func() string { return `{"name":"electronics"}` },
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "create the root category",
+			method: http.MethodPost,
+			path:   path("/category"),
+			token:  &admin,
+			body:   func() string { return `{"name":"electronics"}` },
+			status: http.StatusCreated,
+			check:  saveID(&rootID),
+		},
+		{
+			name:   "create a child category",
+			method: http.MethodPost,
+			path:   path("/category"),
+			token:  &admin,
+			body:   func() string { return `{"name":"phones","parent_id":"` + rootID + `"}` },
+			status: http.StatusCreated,
+			check:  saveID(&childID),
+		},
+		{
+			name:   "read the category tree",
+			method: http.MethodGet,
+			path:   path("/category/tree"),
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var tree []struct {
+					Id       string `json:"id"`
+					Children []struct {
+						Id   string `json:"id"`
+						Name string `json:"name"`
+					} `json:"children"`
+				}
+				decode(t, data, &tree)
+
+				if len(tree) != 1 || tree[0].Id != rootID || len(tree[0].Children) != 1 || tree[0].Children[0].Id != childID {
+					t.Errorf("tree = %s, want electronics with phones under it", data)
+				}
+			},
+		},
+		{
+			name:   "read the path of the child category",
+			method: http.MethodGet,
+			path:   func() string { return "/category/" + childID + "/path" },
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				if !bytes.Contains(data, []byte(`"electronics"`)) || !bytes.Contains(data, []byte(`"phones"`)) {
+					t.Errorf("path = %s, want electronics and phones", data)
+				}
+			},
+		},
+		{
+			name:   "read a category by an id that is not a UUID",
+			method: http.MethodGet,
+			path:   path("/category/not-a-uuid"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "filter products by a category id that is not a UUID",
+			method: http.MethodGet,
+			path:   path("/product?category_id=not-a-uuid"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create a product with an invalid body",
+			method: http.MethodPost,
+			path:   path("/product"),
+			token:  &admin,
+			body:   func() string { return `{"price":"10","category_id":"` + childID + `"}` },
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "create a product",
+			method: http.MethodPost,
+			path:   path("/product"),
+			token:  &admin,
+			body: func() string {
+				return `{"name":"phone","price":"100","category_id":"` + childID + `","stock_quantity":10}`
+			},
+			status: http.StatusCreated,
+			check:  saveID(&productID),
+		},
+		{
+			name:   "order the product as the client",
+			method: http.MethodPost,
+			path:   path("/order"),
+			token:  &client,
+			body: func() string {
+				return `{"description":"gift","items":[{"product_id":"` + productID + `","quantity":2}]}`
+			},
+			status: http.StatusCreated,
+			check:  saveID(&orderID),
+		},
+		{
+			name:   "read the order with its product and category",
+			method: http.MethodGet,
+			path:   func() string { return "/order/" + orderID },
+			token:  &client,
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var order struct {
+					Items []struct {
+						Product struct {
+							Id       string `json:"id"`
+							Name     string `json:"name"`
+							Category struct {
+								Id   string `json:"id"`
+								Name string `json:"name"`
+							} `json:"category"`
+						} `json:"product"`
+						Quantity int    `json:"quantity"`
+						Subtotal string `json:"subtotal"`
+					} `json:"items"`
+				}
+				decode(t, data, &order)
+
+				if len(order.Items) != 1 {
+					t.Fatalf("order has %d items, want 1: %s", len(order.Items), data)
+				}
+
+				item := order.Items[0]
+				if item.Product.Id != productID || item.Product.Name != "phone" ||
+					item.Product.Category.Id != childID || item.Product.Category.Name != "phones" ||
+					item.Quantity != 2 || item.Subtotal != "200" {
+					t.Errorf("order item = %+v, want 2 phones of the phones category for 200", item)
+				}
+			},
+		},
+		{
+			name:   "read the order as another client",
+			method: http.MethodGet,
+			path:   func() string { return "/order/" + orderID },
+			token:  &other,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "the order reserved the stock",
+			method: http.MethodGet,
+			path:   func() string { return "/product/" + productID },
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var product struct {
+					StockQuantity int `json:"stock_quantity"`
+				}
+				decode(t, data, &product)
+
+				if product.StockQuantity != 8 {
+					t.Errorf("stock_quantity = %d, want 8", product.StockQuantity)
+				}
+			},
+		},
+		{
+			name:   "delete the order",
+			method: http.MethodDelete,
+			path:   func() string { return "/order/" + orderID },
+			token:  &admin,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "a deleted order is not found",
+			method: http.MethodGet,
+			path:   func() string { return "/order/" + orderID },
+			token:  &admin,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "a deleted order cannot be updated",
+			method: http.MethodPut,
+			path:   func() string { return "/order/" + orderID },
+			token:  &admin,
+			body:   func() string { return `{"description":"changed"}` },
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "a deleted order is left out of the list",
+			method: http.MethodGet,
+			path:   path("/order"),
+			token:  &client,
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var list struct {
+					Orders []json.RawMessage `json:"orders"`
+				}
+				decode(t, data, &list)
+
+				if len(list.Orders) != 0 {
+					t.Errorf("orders = %s, want none", data)
+				}
+			},
+		},
+		{
+			name:   "deleting the order gave the stock back",
+			method: http.MethodGet,
+			path:   func() string { return "/product/" + productID },
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				var product struct {
+					StockQuantity int `json:"stock_quantity"`
+				}
+				decode(t, data, &product)
+
+				if product.StockQuantity != 10 {
+					t.Errorf("stock_quantity = %d, want 10", product.StockQuantity)
+				}
+			},
+		},
+		{
+			name:   "the product has its price history",
+			method: http.MethodGet,
+			path:   func() string { return "/product/" + productID + "/prices" },
+			token:  &admin,
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				productPrices = prices(t)
+			},
+		},
+		{
+			name:   "delete the product",
+			method: http.MethodDelete,
+			path:   func() string { return "/product/" + productID },
+			token:  &admin,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "a deleted product is not found",
+			method: http.MethodGet,
+			path:   func() string { return "/product/" + productID },
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "a deleted product is left out of the list",
+			method: http.MethodGet,
+			path:   path("/product"),
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				if bytes.Contains(data, []byte(productID)) {
+					t.Errorf("products = %s, want the deleted one left out", data)
+				}
+			},
+		},
+		{
+			name:   "a deleted product cannot be updated",
+			method: http.MethodPut,
+			path:   func() string { return "/product/" + productID },
+			token:  &admin,
+			body: func() string {
+				return `{"name":"tablet","price":"150","category_id":"` + childID + `"}`
+			},
+			status: http.StatusNotFound,
+			check: func(t *testing.T, data json.RawMessage) {
+				if got := prices(t); got != productPrices {
+					t.Errorf("price history has %d periods, want %d", got, productPrices)
+				}
+			},
+		},
+		{
+			name:   "deleting it again is a no-op",
+			method: http.MethodDelete,
+			path:   func() string { return "/product/" + productID },
+			token:  &admin,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "a category with childs cannot be deleted",
+			method: http.MethodDelete,
+			path:   func() string { return "/category/" + rootID },
+			token:  &admin,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "delete the child category",
+			method: http.MethodDelete,
+			path:   func() string { return "/category/" + childID },
+			token:  &admin,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "a deleted category is not found",
+			method: http.MethodGet,
+			path:   func() string { return "/category/" + childID },
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "a deleted category is left out of the tree",
+			method: http.MethodGet,
+			path:   path("/category/tree"),
+			status: http.StatusOK,
+			check: func(t *testing.T, data json.RawMessage) {
+				if bytes.Contains(data, []byte(childID)) {
+					t.Errorf("tree = %s, want the deleted category left out", data)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"crud/api/http"
+	"crud/config"
+	"crud/models"
+	"crud/pkg/helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// AuthMiddleware lets the request through only with a valid access token
+// whose role is one of roles. The user id and role are stored in the gin
+// context under "user_id" and "role".
+func (h *HandlerV1) AuthMiddleware(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		claims, err := h.parseToken(c.GetHeader("Authorization"), accessTokenType)
+		if err != nil {
+			log.Printf("error whiling auth: %v\n", err)
+			h.handleResponse(c, http.Unauthorized, errors.New("unauthorized").Error())
+			c.Abort()
+			return
+		}
+
+		role, _ := claims["role"].(string)
+		if !hasRole(roles, role) {
+			log.Printf("error whiling auth: role %q is not allowed\n", role)
+			h.handleResponse(c, http.Forbidden, errors.New("permission denied").Error())
+			c.Abort()
+			return
+		}
+
+		userId, _ := claims["id"].(string)
+
+		c.Set("user_id", userId)
+		c.Set("role", role)
+
+		c.Next()
+	}
+}
+
+// parseToken accepts both "Bearer <token>" and a bare token.
+func (h *HandlerV1) parseToken(header string, tokenType string) (jwt.MapClaims, error) {
+
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+
+	claims, err := helper.ParseClaims(token, h.cfg.AuthSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims["type"] != tokenType {
+		return nil, errors.New("wrong token type")
+	}
+
+	return claims, nil
+}
+
+func (h *HandlerV1) generateTokens(user *models.User) (*models.LoginResponse, error) {
+
+	claims := map[string]interface{}{
+		"id":   user.Id,
+		"role": user.Role,
+	}
+
+	claims["type"] = accessTokenType
+	accessToken, err := helper.GenerateJWT(claims, config.AccessTokenTTL, h.cfg.AuthSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims["type"] = refreshTokenType
+	refreshToken, err := helper.GenerateJWT(claims, config.RefreshTokenTTL, h.cfg.AuthSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
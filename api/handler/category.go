@@ -1,13 +1,13 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"log"
-	"net/http"
 	"strconv"
 
+	"crud/api/http"
 	"crud/models"
+	"crud/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,39 +20,41 @@ import (
 // @Tags Category
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param category body models.CreateCategory true "CreateCategoryRequestBody"
-// @Success 201 {object} models.Category "GetCategoryBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 201 {object} http.Response{data=models.CategoryList} "GetCategoryBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Conflict"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) CreateCategory(c *gin.Context) {
 	var category models.CreateCategory
 
 	err := c.ShouldBindJSON(&category)
 	if err != nil {
-		log.Printf("error whiling create: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "create", err)
 		return
 	}
 
-	id, err := h.storage.Category().Create(context.Background(), &category)
+	id, err := h.storage.Category().Create(c.Request.Context(), &category)
 	if err != nil {
-		log.Printf("error whiling Create: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling Create").Error())
+		h.handleError(c, "Create", err)
 		return
 	}
 
 	resp, err := h.storage.Category().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.CategoryPrimaryKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, resp)
+	h.handleResponse(c, http.Created, resp)
 }
 
 // GetByIdCategory godoc
@@ -64,25 +66,31 @@ func (h *HandlerV1) CreateCategory(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
-// @Success 200 {object} models.Category "GetCategoryBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.CategoryList} "GetCategoryBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetCategoryById(c *gin.Context) {
 
-	id := c.Param("id")
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
 
 	resp, err := h.storage.Category().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.CategoryPrimaryKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // GetListCategory godoc
@@ -95,51 +103,30 @@ func (h *HandlerV1) GetCategoryById(c *gin.Context) {
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
-// @Success 200 {object} models.GetListCategoryResponse "GetCategoryBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_count query bool false "compute the total count, default true without a cursor"
+// @Success 200 {object} http.Response{data=models.GetListCategoryResponse} "GetCategoryBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetCategoryList(c *gin.Context) {
-	var (
-		limit  int
-		offset int
-		err    error
-	)
-
-	limitStr := c.Query("limit")
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
-	}
-
-	offsetStr := c.Query("offset")
-	if offsetStr != "" {
-		offset, err = strconv.Atoi(offsetStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
+	page, err := parsePage(c)
+	if err != nil {
+		log.Printf("error whiling page: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
 	}
 
 	resp, err := h.storage.Category().GetList(
-		context.Background(),
-		&models.GetListCategoryRequest{
-			Limit:  int32(limit),
-			Offset: int32(offset),
-		},
+		c.Request.Context(),
+		&models.GetListCategoryRequest{Page: page},
 	)
 
 	if err != nil {
-		log.Printf("error whiling get list: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling get list").Error())
+		h.handleError(c, "get list", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	h.handleResponse(c, http.OK, resp)
 
 }
 
@@ -151,63 +138,157 @@ func (h *HandlerV1) GetCategoryList(c *gin.Context) {
 // @Tags Category
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param category body models.UpdateCategorySwagger true "CreateCategoryRequestBody"
-// @Success 200 {object} models.Category "GetCategorysBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.CategoryList} "GetCategorysBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 409 {object} http.Response{data=string} "Category Cycle"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) UpdateCategory(c *gin.Context) {
 
 	var (
 		category models.UpdateCategory
 	)
 
-	id := c.Param("id")
-
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required category id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required category id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
 		return
 	}
 
 	err := c.ShouldBindJSON(&category)
 	if err != nil {
-		log.Printf("error whiling update: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "update", err)
 		return
 	}
 
 	category.Id = id
 
+	category.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
 	rowsAffected, err := h.storage.Category().Update(
-		context.Background(),
+		c.Request.Context(),
+		&category,
+	)
+
+	if err != nil {
+		h.handleError(c, "update", err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		h.handleError(c, "update rows affected", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storage.Category().GetByPKey(
+		c.Request.Context(),
+		&models.CategoryPrimaryKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// PatchCategory godoc
+// @ID patch_category
+// @Router /category/{id} [PATCH]
+// @Summary Patch Category
+// @Description JSON Merge Patch: only the supplied fields change, parent_id: null makes it a root category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param category body models.UpdateCategorySwagger true "PatchCategoryRequestBody, any subset of the fields"
+// @Success 200 {object} http.Response{data=models.CategoryList} "GetCategoryBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 409 {object} http.Response{data=string} "Name Exists or Category Cycle"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) PatchCategory(c *gin.Context) {
+
+	var (
+		category models.PatchCategory
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&category)
+	if err != nil {
+		h.handleBindingError(c, "patch", err)
+		return
+	}
+
+	if fields := validatePatch(&category, &models.UpdateCategory{}); len(fields) > 0 {
+		log.Printf("error whiling patch: invalid fields %v\n", fields)
+		h.handleResponse(c, http.UnprocessableEntity, fields)
+		return
+	}
+
+	category.Id = id
+
+	category.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	rowsAffected, err := h.storage.Category().Patch(
+		c.Request.Context(),
 		&category,
 	)
 
 	if err != nil {
-		log.Printf("error whiling update: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update").Error())
+		h.handleError(c, "patch", err)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("error whiling update rows affected: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update rows affected").Error())
+		h.handleError(c, "patch rows affected", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storage.Category().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.CategoryPrimaryKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // DeleteByIdCategory godoc
@@ -218,31 +299,166 @@ func (h *HandlerV1) UpdateCategory(c *gin.Context) {
 // @Tags Category
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
-// @Success 200 {object} models.Category "GetCategoryBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param policy query string false "restrict (default), cascade or reparent"
+// @Success 204 {object} http.Response{data=string} "No Content"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Category In Use"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) DeleteCategory(c *gin.Context) {
 
-	id := c.Param("id")
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required category id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required category id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+	policy := c.DefaultQuery("policy", models.DeletePolicyRestrict)
+	switch policy {
+	case models.DeletePolicyRestrict, models.DeletePolicyCascade, models.DeletePolicyReparent:
+	default:
+		log.Printf("error whiling delete: unknown policy %q\n", policy)
+		h.handleResponse(c, http.BadRequest, errors.New("policy must be one of restrict, cascade, reparent").Error())
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	err = h.storage.Category().Delete(
+		c.Request.Context(),
+		&models.DeleteCategory{
+			Id:      id,
+			Policy:  policy,
+			Version: version,
+		},
+	)
+
+	if err != nil {
+		h.handleError(c, "delete", err)
+		return
+	}
+
+	h.handleResponse(c, http.NoContent, nil)
+}
+
+// GetCategoryTree godoc
+// @ID get_category_tree
+// @Router /category/tree [GET]
+// @Summary Get Category Tree
+// @Description Get the nested hierarchy of all root categories
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param depth query int false "depth, 0 for unlimited"
+// @Success 200 {object} http.Response{data=[]models.CategoryTree} "GetCategoryTreeBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetCategoryTree(c *gin.Context) {
+	h.getCategoryTree(c, "")
+}
+
+// GetCategorySubtree godoc
+// @ID get_category_subtree
+// @Router /category/{id}/tree [GET]
+// @Summary Get Category Subtree
+// @Description Get the nested hierarchy below a category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param depth query int false "depth, 0 for unlimited"
+// @Success 200 {object} http.Response{data=models.CategoryTree} "GetCategoryTreeBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetCategorySubtree(c *gin.Context) {
+
+	id, ok := h.pathID(c)
+	if !ok {
 		return
 	}
 
-	err := h.storage.Category().Delete(
-		context.Background(),
-		&models.CategoryPrimaryKey{
-			Id: id,
+	h.getCategoryTree(c, id)
+}
+
+func (h *HandlerV1) getCategoryTree(c *gin.Context, id string) {
+	var (
+		depth int
+		err   error
+	)
+
+	depthStr := c.Query("depth")
+	if depthStr != "" {
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil {
+			log.Printf("error whiling depth: %v\n", err)
+			h.handleResponse(c, http.BadRequest, err.Error())
+			return
+		}
+	}
+
+	resp, err := h.storage.Category().GetTree(
+		c.Request.Context(),
+		&models.GetCategoryTreeRequest{
+			Id:    id,
+			Depth: int32(depth),
 		},
 	)
 
 	if err != nil {
-		log.Printf("error whiling delete: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling delete").Error())
+		h.handleError(c, "get tree", err)
+		return
+	}
+
+	if id != "" {
+		h.handleResponse(c, http.OK, resp[0])
+		return
+	}
+
+	if resp == nil {
+		resp = []*models.CategoryTree{}
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// GetCategoryPath godoc
+// @ID get_category_path
+// @Router /category/{id}/path [GET]
+// @Summary Get Category Path
+// @Description Get the breadcrumb of a category, from the root down to the category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} http.Response{data=models.CategoryPath} "GetCategoryPathBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetCategoryPath(c *gin.Context) {
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storage.Category().GetPath(
+		c.Request.Context(),
+		&models.CategoryPrimaryKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "get path", err)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	h.handleResponse(c, http.OK, resp)
 }
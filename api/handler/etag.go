@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag exposes the row version, so a client can send it back in If-Match.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatch reads the version a PUT or DELETE expects to replace. No header
+// or "*" give 0, which skips the version check.
+func ifMatch(c *gin.Context) (int, error) {
+
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version <= 0 {
+		return 0, errors.New("If-Match must be an ETag returned by GET")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud/api/http"
+	"crud/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many CSV rows an export writes between flushes to
+// the client.
+const exportFlushRows = 500
+
+var (
+	orderExportHeader = []string{
+		"order_id", "user_id", "description", "status", "currency", "order_total", "created_at",
+		"item_id", "product_id", "product_name", "category_id", "category_name", "quantity", "price", "subtotal",
+	}
+
+	productExportHeader = []string{
+		"id", "name", "price", "currency", "category_id", "category_name", "stock_quantity", "created_at", "updated_at",
+	}
+)
+
+// ExportOrder godoc
+// @ID export_order
+// @Router /order/export [GET]
+// @Summary Export Orders
+// @Description Streams every order as CSV, one line per order item with the order, product and category names flattened onto it.
+// @Description Clients only export their own orders.
+// @Tags Order
+// @Produce text/csv
+// @Security ApiKeyAuth
+// @Param format query string false "csv, the only format for now"
+// @Success 200 {string} string "CSV file"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) ExportOrder(c *gin.Context) {
+
+	err := exportFormat(c)
+	if err != nil {
+		log.Printf("error whiling export: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	req := &models.GetListOrderRequest{}
+
+	// clients only see their own orders
+	if c.GetString("role") == h.cfg.Client {
+		req.UserID = c.GetString("user_id")
+	}
+
+	h.exportCSV(c, "orders", orderExportHeader, func(write func([]string) error) error {
+		return h.storage.Order().Export(c.Request.Context(), req, func(row *models.OrderExportRow) error {
+			return write([]string{
+				row.OrderID,
+				row.UserID,
+				csvText(row.Description),
+				row.Status,
+				row.Currency,
+				row.Total.String(),
+				row.CreatedAt,
+				row.ItemID,
+				row.ProductID,
+				csvText(row.ProductName),
+				row.CategoryID,
+				csvText(row.CategoryName),
+				strconv.Itoa(row.Quantity),
+				row.Price.String(),
+				row.Subtotal.String(),
+			})
+		})
+	})
+}
+
+// ExportProduct godoc
+// @ID export_product
+// @Router /product/export [GET]
+// @Summary Export Products
+// @Description Streams the products matching the filters of the product list as CSV, with the name of their category.
+// @Tags Product
+// @Produce text/csv
+// @Security ApiKeyAuth
+// @Param format query string false "csv, the only format for now"
+// @Param category_id query string false "category_id"
+// @Param include_descendants query bool false "include products of descendant categories"
+// @Param min_price query string false "min_price"
+// @Param max_price query string false "max_price"
+// @Param search query string false "full-text search by name"
+// @Param sort query string false "price, name or created_at"
+// @Param order query string false "asc or desc"
+// @Success 200 {string} string "CSV file"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) ExportProduct(c *gin.Context) {
+
+	err := exportFormat(c)
+	if err != nil {
+		log.Printf("error whiling export: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	req := &models.GetListProductRequest{}
+
+	err = parseProductFilters(c, req)
+	if err != nil {
+		log.Printf("error whiling product filters: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	h.exportCSV(c, "products", productExportHeader, func(write func([]string) error) error {
+		return h.storage.Product().Export(c.Request.Context(), req, func(row *models.ProductExportRow) error {
+			return write([]string{
+				row.Id,
+				csvText(row.Name),
+				row.Price.String(),
+				row.Currency,
+				row.CategoryID,
+				csvText(row.CategoryName),
+				strconv.Itoa(row.StockQuantity),
+				row.CreatedAt,
+				row.UpdatedAt,
+			})
+		})
+	})
+}
+
+func exportFormat(c *gin.Context) error {
+
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		return errors.New("format must be csv")
+	}
+
+	return nil
+}
+
+// exportCSV streams the records export writes as a CSV attachment. The
+// status and the header row go out with the first record, so an export
+// that fails before it still gets a proper error response.
+func (h *HandlerV1) exportCSV(c *gin.Context, name string, header []string, export func(write func([]string) error) error) {
+
+	var (
+		w       = csv.NewWriter(c.Writer)
+		started bool
+		written int
+	)
+
+	start := func() error {
+		started = true
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+name+"-"+time.Now().UTC().Format("20060102")+`.csv"`)
+		c.Status(http.OK.Code)
+
+		return w.Write(header)
+	}
+
+	err := export(func(record []string) error {
+		if !started {
+			err := start()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := w.Write(record)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+
+		return w.Error()
+	})
+
+	if err != nil && !started {
+		h.handleError(c, "export", err)
+		return
+	}
+
+	if err != nil {
+		log.Printf("error whiling export %s after %d rows: %v\n", name, written, err)
+
+		// a short file would pass for a whole one, so the connection is cut
+		// before the end of the response instead
+		w.Flush()
+		if conn, _, err := c.Writer.Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+
+	// an empty export is the header row alone
+	if !started {
+		_ = start()
+	}
+
+	w.Flush()
+
+	if err = w.Error(); err != nil {
+		log.Printf("error whiling export %s: %v\n", name, err)
+	}
+}
+
+// csvText keeps a text cell from being read as a formula by spreadsheets.
+func csvText(value string) string {
+
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
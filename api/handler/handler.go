@@ -1,8 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"log"
+
+	"crud/api/http"
 	"crud/config"
+	"crud/pkg/helper"
 	"crud/storage"
+
+	"github.com/gin-gonic/gin"
 )
 
 type HandlerV1 struct {
@@ -11,8 +18,62 @@ type HandlerV1 struct {
 }
 
 func NewHandlerV1(cfg *config.Config, storage storage.StorageI) *HandlerV1 {
+
+	registerJSONFieldNames()
+	registerDecimalType()
+	registerHTTPURL()
+
 	return &HandlerV1{
 		cfg:     cfg,
 		storage: storage,
 	}
 }
+
+func (h *HandlerV1) handleResponse(c *gin.Context, status http.Status, data interface{}) {
+	c.JSON(status.Code, http.Response{
+		Status:      status.Status,
+		Description: status.Description,
+		Data:        data,
+	})
+}
+
+// handleError logs err and answers with the status of its storage.ErrorKind.
+// Internal errors are not exposed to the client.
+func (h *HandlerV1) handleError(c *gin.Context, message string, err error) {
+
+	log.Printf("error whiling %s: %v\n", message, err)
+
+	switch storage.KindOf(err) {
+	case storage.KindNotFound:
+		h.handleResponse(c, http.NotFound, err.Error())
+	case storage.KindConflict:
+		h.handleResponse(c, http.Conflict, err.Error())
+	case storage.KindPrecondition:
+		h.handleResponse(c, http.PreconditionFailed, err.Error())
+	case storage.KindValidation:
+		var e *storage.Error
+		errors.As(err, &e)
+
+		h.handleResponse(c, http.UnprocessableEntity, []http.FieldError{{
+			Field:  e.Field,
+			Reason: e.Message,
+		}})
+	default:
+		h.handleResponse(c, http.InternalServerError, "error whiling "+message)
+	}
+}
+
+// pathID reads the id path parameter. An id that is not a UUID cannot name
+// anything, so it is answered with 400 here instead of reaching the storage.
+func (h *HandlerV1) pathID(c *gin.Context) (string, bool) {
+
+	id := c.Param("id")
+
+	if !helper.IsUUID(id) {
+		log.Printf("error whiling id: %q is not a UUID\n", id)
+		h.handleResponse(c, http.BadRequest, errors.New("id must be a UUID").Error())
+		return "", false
+	}
+
+	return id, true
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"log"
+
+	"crud/api/http"
+	"crud/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz godoc
+// @ID healthz
+// @Router /healthz [GET]
+// @Summary Liveness Probe
+// @Description Answers as long as the process is serving requests
+// @Tags Health
+// @Produce json
+// @Success 200 {object} http.Response{data=string} "OK"
+func (h *HandlerV1) Healthz(c *gin.Context) {
+	h.handleResponse(c, http.OK, "ok")
+}
+
+// Readyz godoc
+// @ID readyz
+// @Router /readyz [GET]
+// @Summary Readiness Probe
+// @Description Checks the database connection and that the schema migrations are applied, answering 503 with "database unavailable" or "migrations pending" otherwise
+// @Tags Health
+// @Produce json
+// @Success 200 {object} http.Response{data=string} "OK"
+// @Failure 503 {object} http.Response{data=string} "Not Ready"
+func (h *HandlerV1) Readyz(c *gin.Context) {
+
+	err := h.storage.Ready(c.Request.Context())
+	if err != nil {
+		log.Printf("error whiling ready: %v\n", err)
+
+		// the probe is unauthenticated, the details stay in the log
+		if errors.Is(err, storage.ErrMigrationsPending) {
+			h.handleResponse(c, http.ServiceUnavailable, "migrations pending")
+		} else {
+			h.handleResponse(c, http.ServiceUnavailable, "database unavailable")
+		}
+		return
+	}
+
+	h.handleResponse(c, http.OK, "ok")
+}
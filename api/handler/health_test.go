@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"crud/config"
+	"crud/storage"
+	"crud/storage/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notReadyStore answers Ready with err.
+type notReadyStore struct {
+	storage.StorageI
+
+	err error
+}
+
+func (s *notReadyStore) Ready(ctx context.Context) error {
+	return s.err
+}
+
+func TestReadyzHidesErrors(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"ping fails", errors.New("dial tcp db.internal:5432: connection refused"), "database unavailable"},
+		{"schema behind", fmt.Errorf("%w: schema is at version 12 (dirty: false), want 13", storage.ErrMigrationsPending), "migrations pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var (
+				h = NewHandlerV1(&config.Config{}, &notReadyStore{StorageI: memory.NewMemory(), err: tt.err})
+				r = gin.New()
+				w = httptest.NewRecorder()
+			)
+
+			r.GET("/readyz", h.Readyz)
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var resp struct {
+				Data string `json:"data"`
+			}
+
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if w.Code != http.StatusServiceUnavailable || resp.Data != tt.want {
+				t.Errorf("Readyz() answered %d %q, want %d %q", w.Code, resp.Data, http.StatusServiceUnavailable, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+
+	"crud/api/http"
+	"crud/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency makes a create endpoint safe to retry. The first request with
+// an Idempotency-Key header is handled and its response stored; a retry with
+// the same key and body gets the stored response back without running the
+// handler again. Requests without the header are passed through.
+func (h *HandlerV1) Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			h.handleResponse(c, http.BadRequest, errors.New("Idempotency-Key must be at most 255 characters long").Error())
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Printf("error whiling read body: %v\n", err)
+			h.handleResponse(c, http.BadRequest, err.Error())
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(body)
+
+		record := &models.IdempotencyKey{
+			UserID:      c.GetString("user_id"),
+			Route:       c.Request.Method + " " + c.FullPath(),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			TTL:         h.cfg.IdempotencyTTL,
+		}
+
+		stored, err := h.storage.Idempotency().Reserve(c.Request.Context(), record)
+		if err != nil {
+			h.handleError(c, "reserve idempotency key", err)
+			c.Abort()
+			return
+		}
+
+		if stored != nil {
+			h.replay(c, record, stored)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// the client may be gone already, which is the case the key is for,
+		// so the outcome is saved without the request context
+		ctx := context.Background()
+
+		release := func() {
+			err := h.storage.Idempotency().Release(ctx, record)
+			if err != nil {
+				log.Printf("error whiling release idempotency key: %v\n", err)
+			}
+		}
+
+		// a panic skips the rest of the middleware on its way to Recovery;
+		// the key is released so the retry runs the handler again instead
+		// of waiting for a response that never comes
+		finished := false
+		defer func() {
+			if !finished {
+				release()
+			}
+		}()
+
+		c.Next()
+
+		finished = true
+
+		if recorder.Status() >= 500 {
+			release()
+			return
+		}
+
+		record.StatusCode = recorder.Status()
+		record.Response = recorder.body.Bytes()
+
+		err = h.storage.Idempotency().Complete(ctx, record)
+		if err != nil {
+			log.Printf("error whiling complete idempotency key: %v\n", err)
+		}
+	}
+}
+
+// replay answers a retried request from the stored record.
+func (h *HandlerV1) replay(c *gin.Context, record, stored *models.IdempotencyKey) {
+
+	if stored.RequestHash != record.RequestHash {
+		log.Printf("error whiling idempotency key %q: reused with a different payload\n", record.Key)
+		h.handleResponse(c, http.UnprocessableEntity, []http.FieldError{{
+			Field:  idempotencyKeyHeader,
+			Reason: "was already used with a different request payload",
+		}})
+		return
+	}
+
+	if stored.StatusCode == 0 {
+		h.handleResponse(c, http.Conflict, errors.New("a request with this Idempotency-Key is still in progress").Error())
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Response)
+}
+
+// responseRecorder keeps a copy of the body written through gin.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
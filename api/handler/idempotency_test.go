@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"crud/config"
+	"crud/storage/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	var (
+		h     = NewHandlerV1(&config.Config{IdempotencyTTL: time.Hour}, memory.NewMemory())
+		r     = gin.New()
+		calls int
+	)
+
+	r.Use(gin.Recovery())
+	r.POST("/thing", h.Idempotency(), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler bug")
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/thing", strings.NewReader(`{"name":"x"}`))
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request answered %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	// the retry runs the handler instead of finding the key in progress
+	if w := send(); w.Code != http.StatusCreated {
+		t.Fatalf("retry answered %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	// and the completed key replays from then on
+	w := send()
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" || calls != 2 {
+		t.Errorf("second retry answered %d, replayed %q after %d calls; want a replayed %d after 2",
+			w.Code, w.Header().Get("Idempotent-Replayed"), calls, http.StatusCreated)
+	}
+}
@@ -0,0 +1,412 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crud/api/http"
+	"crud/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// maxImportLine bounds one NDJSON line of an import.
+const maxImportLine = 1 << 20
+
+// ImportCategory godoc
+// @ID import_category
+// @Router /category/import [POST]
+// @Summary Import Categories
+// @Description Imports categories from CSV with a header row (name,parent) or from NDJSON, one {"name","parent"} object per line.
+// @Description parent is the id, the name or the "/" separated path of the parent, empty for a root category.
+// @Description A category that already exists under the same parent is accepted as is, so a catalog can be imported again.
+// @Description All rows are written in one transaction; when any row has an error nothing is written and the errors are answered with 422.
+// @Tags Category
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Security ApiKeyAuth
+// @Param format query string false "csv or ndjson, taken from Content-Type when missing"
+// @Param dry_run query bool false "check the rows without writing them"
+// @Param create_missing query bool false "create the parents that do not exist"
+// @Param rows body string true "CSV or NDJSON rows"
+// @Success 200 {object} http.Response{data=models.ImportResult} "Dry run result"
+// @Success 201 {object} http.Response{data=models.ImportResult} "Import result"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Conflict"
+// @Failure 422 {object} http.Response{data=models.ImportResult} "Rows with errors"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) ImportCategory(c *gin.Context) {
+
+	req := &models.ImportCategories{}
+
+	format, err := importOptions(c, &req.DryRun, &req.CreateMissing)
+	if err != nil {
+		log.Printf("error whiling import: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	rows, total, rowErrs, err := decodeImport[models.ImportCategoryRow](c.Request.Body, format, h.cfg.ImportMaxRows)
+	if err != nil {
+		log.Printf("error whiling import: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	dryRun := req.DryRun
+	req.Rows = rows
+	req.DryRun = dryRun || len(rowErrs) > 0
+
+	resp, err := h.storage.Category().Import(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, "import", err)
+		return
+	}
+
+	h.importResponse(c, resp, dryRun, total, rowErrs)
+}
+
+// ImportProduct godoc
+// @ID import_product
+// @Router /product/import [POST]
+// @Summary Import Products
+// @Description Imports products from CSV with a header row (name,price,currency,category,stock_quantity) or from NDJSON, one object with those fields per line.
+// @Description category is the id, the name or the "/" separated path of the category of the product.
+// @Description All rows are written in one transaction; when any row has an error nothing is written and the errors are answered with 422.
+// @Tags Product
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Security ApiKeyAuth
+// @Param format query string false "csv or ndjson, taken from Content-Type when missing"
+// @Param dry_run query bool false "check the rows without writing them"
+// @Param create_missing query bool false "create the categories that do not exist"
+// @Param rows body string true "CSV or NDJSON rows"
+// @Success 200 {object} http.Response{data=models.ImportResult} "Dry run result"
+// @Success 201 {object} http.Response{data=models.ImportResult} "Import result"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Conflict"
+// @Failure 422 {object} http.Response{data=models.ImportResult} "Rows with errors"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) ImportProduct(c *gin.Context) {
+
+	req := &models.ImportProducts{}
+
+	format, err := importOptions(c, &req.DryRun, &req.CreateMissing)
+	if err != nil {
+		log.Printf("error whiling import: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	rows, total, rowErrs, err := decodeImport[models.ImportProductRow](c.Request.Body, format, h.cfg.ImportMaxRows)
+	if err != nil {
+		log.Printf("error whiling import: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	for _, row := range rows {
+		if row.Currency == "" {
+			row.Currency = models.DefaultCurrency
+		}
+	}
+
+	dryRun := req.DryRun
+	req.Rows = rows
+	req.DryRun = dryRun || len(rowErrs) > 0
+
+	resp, err := h.storage.Product().Import(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, "import", err)
+		return
+	}
+
+	h.importResponse(c, resp, dryRun, total, rowErrs)
+}
+
+// importResponse adds the errors of the rows that never reached the storage
+// to resp: 422 when there are any, otherwise 201 for an import and 200 for
+// a dry run.
+func (h *HandlerV1) importResponse(c *gin.Context, resp *models.ImportResult, dryRun bool, total int, rowErrs []models.ImportError) {
+
+	resp.DryRun = dryRun
+	resp.Rows = total
+	resp.Errors = append(resp.Errors, rowErrs...)
+
+	sort.SliceStable(resp.Errors, func(i, j int) bool {
+		return resp.Errors[i].Line < resp.Errors[j].Line
+	})
+
+	switch {
+	case len(resp.Errors) > 0:
+		h.handleResponse(c, http.UnprocessableEntity, resp)
+	case dryRun:
+		h.handleResponse(c, http.OK, resp)
+	default:
+		h.handleResponse(c, http.Created, resp)
+	}
+}
+
+// importOptions reads the format of the body and the dry_run and
+// create_missing flags of an import.
+func importOptions(c *gin.Context, dryRun, createMissing *bool) (string, error) {
+
+	for name, flag := range map[string]*bool{"dry_run": dryRun, "create_missing": createMissing} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be true or false", name)
+		}
+
+		*flag = parsed
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+		switch mediaType {
+		case "text/csv":
+			format = models.ImportFormatCSV
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			format = models.ImportFormatNDJSON
+		}
+	}
+
+	switch format {
+	case models.ImportFormatCSV, models.ImportFormatNDJSON:
+		return format, nil
+	}
+
+	return "", errors.New("format must be csv or ndjson, set it with the format parameter or Content-Type")
+}
+
+// decodeImport reads the rows of an import body and validates each one with
+// its binding tags. It returns the valid rows, the number of rows read and
+// the errors of the invalid ones by line; err is only set when the body as a
+// whole cannot be read.
+func decodeImport[T any](r io.Reader, format string, maxRows int) ([]*T, int, []models.ImportError, error) {
+
+	var (
+		rows  []*T
+		total int
+		errs  []models.ImportError
+	)
+
+	next := func(line int, row *T, rowErr *models.ImportError) error {
+		total++
+		if total > maxRows {
+			return fmt.Errorf("import has more than %d rows", maxRows)
+		}
+
+		if rowErr != nil {
+			rowErr.Line = line
+			errs = append(errs, *rowErr)
+			return nil
+		}
+
+		reflect.ValueOf(row).Elem().FieldByName("Line").SetInt(int64(line))
+
+		var validationErrs validator.ValidationErrors
+		if errors.As(binding.Validator.ValidateStruct(row), &validationErrs) {
+			for _, fe := range fieldErrors(validationErrs) {
+				errs = append(errs, models.ImportError{Line: line, Field: fe.Field, Reason: fe.Reason})
+			}
+			return nil
+		}
+
+		rows = append(rows, row)
+
+		return nil
+	}
+
+	var err error
+
+	switch format {
+	case models.ImportFormatCSV:
+		err = decodeCSV(r, next)
+	default:
+		err = decodeNDJSON(r, next)
+	}
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return rows, total, errs, nil
+}
+
+// decodeCSV maps the columns to the fields of T by the names in the header
+// row, which are the json names of the fields.
+func decodeCSV[T any](r io.Reader, next func(int, *T, *models.ImportError) error) error {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var (
+		rowType = reflect.TypeOf((*T)(nil)).Elem()
+		columns = make([]int, len(header))
+	)
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+
+		columns[i] = -1
+		for f := 0; f < rowType.NumField(); f++ {
+			if strings.SplitN(rowType.Field(f).Tag.Get("json"), ",", 2)[0] == name && name != "-" {
+				columns[i] = f
+			}
+		}
+
+		if columns[i] < 0 {
+			return fmt.Errorf("unknown column %q", name)
+		}
+
+		header[i] = name
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if len(record) != len(header) {
+			err = next(line, nil, &models.ImportError{
+				Reason: fmt.Sprintf("has %d columns, the header has %d", len(record), len(header)),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var (
+			row    = new(T)
+			rv     = reflect.ValueOf(row).Elem()
+			rowErr *models.ImportError
+		)
+
+		for i, value := range record {
+			err = setImportField(rv.Field(columns[i]), strings.TrimSpace(value))
+			if err != nil {
+				rowErr = &models.ImportError{Field: header[i], Reason: err.Error()}
+				break
+			}
+		}
+
+		err = next(line, row, rowErr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// setImportField sets a field from its CSV text; empty text leaves the
+// zero value for the binding tags to judge.
+func setImportField(field reflect.Value, value string) error {
+
+	if value == "" {
+		return nil
+	}
+
+	switch field.Interface().(type) {
+	case decimal.Decimal:
+		amount, err := decimal.NewFromString(value)
+		if err != nil {
+			return errors.New("must be a decimal number")
+		}
+		field.Set(reflect.ValueOf(amount))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		field.SetInt(int64(n))
+	}
+
+	return nil
+}
+
+// decodeNDJSON reads one JSON object per line, skipping blank lines.
+func decodeNDJSON[T any](r io.Reader, next func(int, *T, *models.ImportError) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var (
+			row     = new(T)
+			rowErr  *models.ImportError
+			typeErr *json.UnmarshalTypeError
+		)
+
+		err := json.Unmarshal([]byte(text), row)
+
+		switch {
+		case errors.As(err, &typeErr):
+			rowErr = &models.ImportError{Field: typeErr.Field, Reason: "must be " + jsonType(typeErr.Type)}
+		case err != nil:
+			rowErr = &models.ImportError{Reason: "invalid JSON: " + err.Error()}
+		}
+
+		err = next(line, row, rowErr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("a line is longer than %d bytes", maxImportLine)
+	}
+
+	return scanner.Err()
+}
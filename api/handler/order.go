@@ -1,13 +1,12 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"log"
-	"net/http"
-	"strconv"
 
+	"crud/api/http"
 	"crud/models"
+	"crud/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,39 +19,44 @@ import (
 // @Tags Order
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "replays the stored response when the request is retried"
 // @Param order body models.CreateOrder true "CreateOrderRequestBody"
-// @Success 201 {object} models.Order "GetorderBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 201 {object} http.Response{data=models.OrderList} "GetorderBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Not Enough Stock or Request In Progress"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) CreateOrder(c *gin.Context) {
 	var order models.CreateOrder
 
 	err := c.ShouldBindJSON(&order)
 	if err != nil {
-		log.Printf("error whiling create: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "create", err)
 		return
 	}
 
-	id, err := h.storage.Order().Create(context.Background(), &order)
+	order.UserID = c.GetString("user_id")
+
+	id, err := h.storage.Order().Create(c.Request.Context(), &order)
 	if err != nil {
-		log.Printf("error whiling Create: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling Create").Error())
+		h.handleError(c, "Create", err)
 		return
 	}
 
 	resp, err := h.storage.Order().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.OrderPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, resp)
+	h.handleResponse(c, http.Created, resp)
 }
 
 // GetByIdOrder godoc
@@ -63,26 +67,42 @@ func (h *HandlerV1) CreateOrder(c *gin.Context) {
 // @Tags Order
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
-// @Success 200 {object} models.Order "GetOrderBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.OrderList} "GetOrderBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetOrderById(c *gin.Context) {
 
-	id := c.Param("id")
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
 
 	resp, err := h.storage.Order().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.OrderPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	// clients may only read their own orders
+	if c.GetString("role") == h.cfg.Client && resp.UserID != c.GetString("user_id") {
+		log.Printf("error whiling GetByPKey: order %s belongs to another user\n", id)
+		h.handleResponse(c, http.Forbidden, errors.New("permission denied").Error())
+		return
+	}
+
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // GetListOrder godoc
@@ -93,53 +113,42 @@ func (h *HandlerV1) GetOrderById(c *gin.Context) {
 // @Tags Order
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
-// @Success 200 {object} models.GetListOrderResponse "GetOrderBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_count query bool false "compute the total count, default true without a cursor"
+// @Success 200 {object} http.Response{data=models.GetListOrderResponse} "GetOrderBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetOrderList(c *gin.Context) {
-	var (
-		limit  int
-		offset int
-		err    error
-	)
-
-	limitStr := c.Query("limit")
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
+	page, err := parsePage(c)
+	if err != nil {
+		log.Printf("error whiling page: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
 	}
 
-	offsetStr := c.Query("offset")
-	if offsetStr != "" {
-		offset, err = strconv.Atoi(offsetStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
+	req := &models.GetListOrderRequest{Page: page}
+
+	// clients only see their own orders
+	if c.GetString("role") == h.cfg.Client {
+		req.UserID = c.GetString("user_id")
 	}
 
 	resp, err := h.storage.Order().GetList(
-		context.Background(),
-		&models.GetListOrderRequest{
-			Limit:  int32(limit),
-			Offset: int32(offset),
-		},
+		c.Request.Context(),
+		req,
 	)
 
 	if err != nil {
-		log.Printf("error whiling get list: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling get list").Error())
+		h.handleError(c, "get list", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	h.handleResponse(c, http.OK, resp)
 
 }
 
@@ -148,66 +157,162 @@ func (h *HandlerV1) GetOrderList(c *gin.Context) {
 // @Router /order/{id} [PUT]
 // @Summary Update Order
 // @Description Update Order
+// @Description Items can only change while the order is pending or paid.
 // @Tags Order
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param order body models.UpdateOrderSwagger true "CreateOrderRequestBody"
-// @Success 200 {object} models.Order "GetordersBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.OrderList} "GetordersBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 409 {object} http.Response{data=string} "Not Enough Stock or Items Of A Shipped Order"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) UpdateOrder(c *gin.Context) {
 
 	var (
 		order models.UpdateOrder
 	)
 
-	id := c.Param("id")
-
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required order id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required order id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
 		return
 	}
 
 	err := c.ShouldBindJSON(&order)
 	if err != nil {
-		log.Printf("error whiling update: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "update", err)
 		return
 	}
 
 	order.Id = id
 
+	order.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
 	rowsAffected, err := h.storage.Order().Update(
-		context.Background(),
+		c.Request.Context(),
 		&order,
 	)
 
 	if err != nil {
-		log.Printf("error whiling update: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update").Error())
+		h.handleError(c, "update", err)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("error whiling update rows affected: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update rows affected").Error())
+		h.handleError(c, "update rows affected", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storage.Order().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.OrderPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// PatchOrder godoc
+// @ID patch_order
+// @Router /order/{id} [PATCH]
+// @Summary Patch Order
+// @Description JSON Merge Patch: only the supplied fields change, description: null clears it and items replace all the lines
+// @Description Items can only change while the order is pending or paid.
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param order body models.UpdateOrderSwagger true "PatchOrderRequestBody, any subset of the fields"
+// @Success 200 {object} http.Response{data=models.OrderList} "GetOrderBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 409 {object} http.Response{data=string} "Not Enough Stock or Items Of A Shipped Order"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) PatchOrder(c *gin.Context) {
+
+	var (
+		order models.PatchOrder
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&order)
+	if err != nil {
+		h.handleBindingError(c, "patch", err)
+		return
+	}
+
+	if fields := validatePatch(&order, &models.CreateOrder{}); len(fields) > 0 {
+		log.Printf("error whiling patch: invalid fields %v\n", fields)
+		h.handleResponse(c, http.UnprocessableEntity, fields)
+		return
+	}
+
+	order.Id = id
+
+	order.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	rowsAffected, err := h.storage.Order().Patch(
+		c.Request.Context(),
+		&order,
+	)
+
+	if err != nil {
+		h.handleError(c, "patch", err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		h.handleError(c, "patch rows affected", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storage.Order().GetByPKey(
+		c.Request.Context(),
+		&models.OrderPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // DeleteByIdOrder godoc
@@ -218,31 +323,110 @@ func (h *HandlerV1) UpdateOrder(c *gin.Context) {
 // @Tags Order
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
-// @Success 200 {object} models.Order "GetOrderBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Success 204 {object} http.Response{data=string} "No Content"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) DeleteOrder(c *gin.Context) {
 
-	id := c.Param("id")
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required order id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required order id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
 		return
 	}
 
-	err := h.storage.Order().Delete(
-		context.Background(),
-		&models.OrderPrimarKey{
-			Id: id,
+	err = h.storage.Order().Delete(
+		c.Request.Context(),
+		&models.DeleteOrder{
+			Id:      id,
+			Version: version,
 		},
 	)
 
 	if err != nil {
-		log.Printf("error whiling delete: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling delete").Error())
+		h.handleError(c, "delete", err)
+		return
+	}
+
+	h.handleResponse(c, http.NoContent, nil)
+}
+
+// TransitionOrder godoc
+// @ID transition_order
+// @Router /order/{id}/transition [POST]
+// @Summary Transition Order Status
+// @Description Move the order to the next status: pending -> paid -> shipped -> delivered, plus cancelled and refunded
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param transition body models.OrderTransitionSwagger true "OrderTransitionRequestBody"
+// @Success 200 {object} http.Response{data=models.OrderList} "GetOrderBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 409 {object} http.Response{data=string} "Illegal Transition"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) TransitionOrder(c *gin.Context) {
+
+	var (
+		transition models.OrderTransition
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&transition)
+	if err != nil {
+		h.handleBindingError(c, "transition", err)
+		return
+	}
+
+	if !models.IsOrderStatus(transition.Status) {
+		log.Printf("error whiling transition: unknown status %q\n", transition.Status)
+		h.handleResponse(c, http.UnprocessableEntity, []http.FieldError{{
+			Field:  "status",
+			Reason: "must be one of pending, paid, shipped, delivered, cancelled, refunded",
+		}})
+		return
+	}
+
+	transition.Id = id
+
+	err = h.storage.Order().Transition(
+		c.Request.Context(),
+		&transition,
+	)
+
+	if err != nil {
+		h.handleError(c, "transition", err)
+		return
+	}
+
+	resp, err := h.storage.Order().GetByPKey(
+		c.Request.Context(),
+		&models.OrderPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	h.handleResponse(c, http.OK, resp)
 }
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"crud/models"
+	"crud/pkg/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePage reads limit, offset, cursor and include_count. The total count
+// is computed by default in offset mode only, it costs a second scan.
+func parsePage(c *gin.Context) (models.Page, error) {
+
+	var page models.Page
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return page, err
+		}
+		page.Limit = int32(limit)
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			return page, err
+		}
+		page.Offset = int32(offset)
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		if page.Offset > 0 {
+			return page, errors.New("cursor and offset cannot be combined")
+		}
+
+		cursor, err := helper.DecodeCursor(cursorStr)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+
+	page.IncludeCount = page.Cursor == nil
+	if includeStr := c.Query("include_count"); includeStr != "" {
+		includeCount, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			return page, err
+		}
+		page.IncludeCount = includeCount
+	}
+
+	return page, nil
+}
+
+// parseLimitOffset reads limit and offset for the short lists that page by
+// offset only.
+func parseLimitOffset(c *gin.Context) (int32, int32, error) {
+
+	page, err := parsePage(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if page.Cursor != nil {
+		return 0, 0, errors.New("cursor is not supported here")
+	}
+
+	return page.Limit, page.Offset, nil
+}
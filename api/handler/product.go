@@ -1,15 +1,18 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"log"
-	"net/http"
 	"strconv"
+	"time"
 
+	"crud/api/http"
 	"crud/models"
+	"crud/pkg/helper"
+	"crud/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 // CreateProduct godoc
@@ -20,39 +23,46 @@ import (
 // @Tags Product
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "replays the stored response when the request is retried"
 // @Param product body models.CreateProduct true "CreateProductRequestBody"
-// @Success 201 {object} models.Product "GetProductBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 201 {object} http.Response{data=models.Product} "GetProductBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Request In Progress"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) CreateProduct(c *gin.Context) {
 	var product models.CreateProduct
 
 	err := c.ShouldBindJSON(&product)
 	if err != nil {
-		log.Printf("error whiling create: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "create", err)
 		return
 	}
 
-	id, err := h.storage.Product().Create(context.Background(), &product)
+	if product.Currency == "" {
+		product.Currency = models.DefaultCurrency
+	}
+
+	id, err := h.storage.Product().Create(c.Request.Context(), &product)
 	if err != nil {
-		log.Printf("error whiling Create: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling Create").Error())
+		h.handleError(c, "Create", err)
 		return
 	}
 
 	resp, err := h.storage.Product().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.ProductPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, resp)
+	h.handleResponse(c, http.Created, resp)
 }
 
 // GetByIdProduct godoc
@@ -64,25 +74,31 @@ func (h *HandlerV1) CreateProduct(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
-// @Success 200 {object} models.Product "GetProductBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.Product} "GetProductBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetProductById(c *gin.Context) {
 
-	id := c.Param("id")
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
 
 	resp, err := h.storage.Product().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.ProductPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // GetListProduct godoc
@@ -95,51 +111,53 @@ func (h *HandlerV1) GetProductById(c *gin.Context) {
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
-// @Success 200 {object} models.GetListProductResponse "GetProductBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_count query bool false "compute the total count, default true without a cursor"
+// @Param category_id query string false "category_id"
+// @Param include_descendants query bool false "include products of descendant categories"
+// @Param min_price query string false "min_price"
+// @Param max_price query string false "max_price"
+// @Param search query string false "full-text search by name"
+// @Param sort query string false "price, name or created_at"
+// @Param order query string false "asc or desc"
+// @Success 200 {object} http.Response{data=models.GetListProductResponse} "GetProductBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) GetProductList(c *gin.Context) {
-	var (
-		limit  int
-		offset int
-		err    error
-	)
+	page, err := parsePage(c)
+	if err != nil {
+		log.Printf("error whiling page: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
 
-	limitStr := c.Query("limit")
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
+	req := &models.GetListProductRequest{Page: page}
+
+	err = parseProductFilters(c, req)
+	if err != nil {
+		log.Printf("error whiling product filters: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
 	}
 
-	offsetStr := c.Query("offset")
-	if offsetStr != "" {
-		offset, err = strconv.Atoi(offsetStr)
-		if err != nil {
-			log.Printf("error whiling limit: %v\n", err)
-			c.JSON(http.StatusBadRequest, err.Error())
-			return
-		}
+	// the cursor is a (created_at, id) position, other sorts page by offset
+	if req.Cursor != nil && req.SortBy != "" && req.SortBy != models.ProductSortCreatedAt {
+		log.Printf("error whiling cursor: cursor with sort %q\n", req.SortBy)
+		h.handleResponse(c, http.BadRequest, errors.New("cursor can only be used with sort=created_at").Error())
+		return
 	}
 
 	resp, err := h.storage.Product().GetList(
-		context.Background(),
-		&models.GetListProductRequest{
-			Limit:  int32(limit),
-			Offset: int32(offset),
-		},
+		c.Request.Context(),
+		req,
 	)
 
 	if err != nil {
-		log.Printf("error whiling get list: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling get list").Error())
+		h.handleError(c, "get list", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	h.handleResponse(c, http.OK, resp)
 
 }
 
@@ -151,63 +169,155 @@ func (h *HandlerV1) GetProductList(c *gin.Context) {
 // @Tags Product
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param product body models.UpdateProductSwagger true "CreateProductRequestBody"
-// @Success 200 {object} models.Product "GetProductsBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Success 200 {object} http.Response{data=models.Product} "GetProductsBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) UpdateProduct(c *gin.Context) {
 
 	var (
 		product models.UpdateProduct
 	)
 
-	id := c.Param("id")
-
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required product id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required product id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
 		return
 	}
 
 	err := c.ShouldBindJSON(&product)
 	if err != nil {
-		log.Printf("error whiling update: %v\n", err)
-		c.JSON(http.StatusBadRequest, err.Error())
+		h.handleBindingError(c, "update", err)
 		return
 	}
 
 	product.Id = id
 
+	product.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
 	rowsAffected, err := h.storage.Product().Update(
-		context.Background(),
+		c.Request.Context(),
+		&product,
+	)
+
+	if err != nil {
+		h.handleError(c, "update", err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		h.handleError(c, "update rows affected", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storage.Product().GetByPKey(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// PatchProduct godoc
+// @ID patch_product
+// @Router /product/{id} [PATCH]
+// @Summary Patch Product
+// @Description JSON Merge Patch: only the supplied fields change
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param product body models.UpdateProductSwagger true "PatchProductRequestBody, any subset of the fields"
+// @Success 200 {object} http.Response{data=models.Product} "GetProductBody"
+// @Header 200 {string} ETag "version of the resource, for If-Match"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) PatchProduct(c *gin.Context) {
+
+	var (
+		product models.PatchProduct
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&product)
+	if err != nil {
+		h.handleBindingError(c, "patch", err)
+		return
+	}
+
+	if fields := validatePatch(&product, &models.UpdateProduct{}); len(fields) > 0 {
+		log.Printf("error whiling patch: invalid fields %v\n", fields)
+		h.handleResponse(c, http.UnprocessableEntity, fields)
+		return
+	}
+
+	product.Id = id
+
+	product.Version, err = ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	rowsAffected, err := h.storage.Product().Patch(
+		c.Request.Context(),
 		&product,
 	)
 
 	if err != nil {
-		log.Printf("error whiling update: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update").Error())
+		h.handleError(c, "patch", err)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("error whiling update rows affected: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling update rows affected").Error())
+		h.handleError(c, "patch rows affected", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storage.Product().GetByPKey(
-		context.Background(),
+		c.Request.Context(),
 		&models.ProductPrimarKey{Id: id},
 	)
 
 	if err != nil {
-		log.Printf("error whiling GetByPKey: %v\n", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling GetByPKey").Error())
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	setETag(c, resp.Version)
+
+	h.handleResponse(c, http.OK, resp)
 }
 
 // DeleteByIdProduct godoc
@@ -218,31 +328,343 @@ func (h *HandlerV1) UpdateProduct(c *gin.Context) {
 // @Tags Product
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "id"
-// @Success 200 {object} models.Product "GetProductBody"
-// @Response 400 {object} string "Invalid Argument"
-// @Failure 500 {object} string "Server Error"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Success 204 {object} http.Response{data=string} "No Content"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 412 {object} http.Response{data=string} "Version Changed"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
 func (h *HandlerV1) DeleteProduct(c *gin.Context) {
 
-	id := c.Param("id")
-	if id == "" {
-		log.Printf("error whiling update: %v\n", errors.New("required product id").Error())
-		c.JSON(http.StatusBadRequest, errors.New("required product id").Error())
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		log.Printf("error whiling If-Match: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	err = h.storage.Product().Delete(
+		c.Request.Context(),
+		&models.DeleteProduct{
+			Id:      id,
+			Version: version,
+		},
+	)
+
+	if err != nil {
+		h.handleError(c, "delete", err)
+		return
+	}
+
+	h.handleResponse(c, http.NoContent, nil)
+}
+
+// RestockProduct godoc
+// @ID restock_product
+// @Router /product/{id}/restock [POST]
+// @Summary Restock Product
+// @Description Add stock to the product and record it in the inventory ledger
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param restock body models.RestockSwagger true "RestockRequestBody"
+// @Success 200 {object} http.Response{data=models.Product} "GetProductBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) RestockProduct(c *gin.Context) {
+
+	var (
+		restock models.Restock
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&restock)
+	if err != nil {
+		h.handleBindingError(c, "restock", err)
+		return
+	}
+
+	restock.ProductID = id
+
+	err = h.storage.Product().Restock(
+		c.Request.Context(),
+		&restock,
+	)
+
+	if err != nil {
+		h.handleError(c, "restock", err)
+		return
+	}
+
+	resp, err := h.storage.Product().GetByPKey(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// GetProductMovements godoc
+// @ID get_product_movements
+// @Router /product/{id}/movements [GET]
+// @Summary Get Product Inventory Movements
+// @Description Stock ledger of the product, newest first
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Success 200 {object} http.Response{data=models.GetListMovementResponse} "GetMovementsBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetProductMovements(c *gin.Context) {
+	var (
+		limit  int
+		offset int
+		err    error
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	limitStr := c.Query("limit")
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			log.Printf("error whiling limit: %v\n", err)
+			h.handleResponse(c, http.BadRequest, err.Error())
+			return
+		}
+	}
+
+	offsetStr := c.Query("offset")
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil {
+			log.Printf("error whiling offset: %v\n", err)
+			h.handleResponse(c, http.BadRequest, err.Error())
+			return
+		}
+	}
+
+	_, err = h.storage.Product().GetByPKey(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
 		return
 	}
 
-	err := h.storage.Product().Delete(
-		context.Background(),
-		&models.ProductPrimarKey{
-			Id: id,
+	resp, err := h.storage.Product().GetMovements(
+		c.Request.Context(),
+		&models.GetListMovementRequest{
+			ProductID: id,
+			Limit:     int32(limit),
+			Offset:    int32(offset),
 		},
 	)
 
 	if err != nil {
-		log.Printf("error whiling delete: %v", err)
-		c.JSON(http.StatusInternalServerError, errors.New("error whiling delete").Error())
+		h.handleError(c, "get movements", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// ScheduleProductPrice godoc
+// @ID schedule_product_price
+// @Router /product/{id}/prices [POST]
+// @Summary Schedule Product Price
+// @Description Schedule a price change, it takes effect at effective_from and runs until the next scheduled change.
+// @Description product.price_changed is emitted right away, with effective_from and scheduled: true for a change in the future; no event follows when it takes effect.
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param price body models.SchedulePriceSwagger true "SchedulePriceRequestBody"
+// @Success 200 {object} http.Response{data=models.ProductPriceHistory} "GetProductPricesBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) ScheduleProductPrice(c *gin.Context) {
+
+	var (
+		schedule models.SchedulePrice
+	)
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&schedule)
+	if err != nil {
+		h.handleBindingError(c, "schedule price", err)
+		return
+	}
+
+	if schedule.EffectiveFrom.Before(time.Now()) {
+		h.handleError(c, "schedule price", storage.NewInvalidField("effective_from", "must not be in the past"))
+		return
+	}
+
+	schedule.ProductID = id
+
+	err = h.storage.Product().SchedulePrice(
+		c.Request.Context(),
+		&schedule,
+	)
+
+	if err != nil {
+		h.handleError(c, "schedule price", err)
+		return
+	}
+
+	resp, err := h.storage.Product().GetPrices(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "get prices", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// GetProductPrices godoc
+// @ID get_product_prices
+// @Router /product/{id}/prices [GET]
+// @Summary Get Product Price History
+// @Description Price schedule of the product ordered by effective_from, including future changes
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Success 200 {object} http.Response{data=models.ProductPriceHistory} "GetProductPricesBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetProductPrices(c *gin.Context) {
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return
+	}
+
+	_, err := h.storage.Product().GetByPKey(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	resp, err := h.storage.Product().GetPrices(
+		c.Request.Context(),
+		&models.ProductPrimarKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "get prices", err)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	h.handleResponse(c, http.OK, resp)
+}
+
+// parseProductFilters reads the filters and the sort of the product list
+// into req.
+func parseProductFilters(c *gin.Context, req *models.GetListProductRequest) error {
+
+	var err error
+
+	req.CategoryID = c.Query("category_id")
+	if req.CategoryID != "" && !helper.IsUUID(req.CategoryID) {
+		return errors.New("category_id must be a UUID")
+	}
+
+	req.Search = c.Query("search")
+
+	includeStr := c.Query("include_descendants")
+	if includeStr != "" {
+		req.IncludeDescendants, err = strconv.ParseBool(includeStr)
+		if err != nil {
+			return err
+		}
+	}
+
+	minPriceStr := c.Query("min_price")
+	if minPriceStr != "" {
+		minPrice, err := decimal.NewFromString(minPriceStr)
+		if err != nil {
+			return err
+		}
+		req.MinPrice = &minPrice
+	}
+
+	maxPriceStr := c.Query("max_price")
+	if maxPriceStr != "" {
+		maxPrice, err := decimal.NewFromString(maxPriceStr)
+		if err != nil {
+			return err
+		}
+		req.MaxPrice = &maxPrice
+	}
+
+	req.SortBy = c.Query("sort")
+	switch req.SortBy {
+	case "", models.ProductSortPrice, models.ProductSortName, models.ProductSortCreatedAt:
+	default:
+		return errors.New("sort must be one of price, name, created_at")
+	}
+
+	req.SortOrder = c.DefaultQuery("order", models.SortAsc)
+	if req.SortOrder != models.SortAsc && req.SortOrder != models.SortDesc {
+		return errors.New("order must be asc or desc")
+	}
+
+	return nil
 }
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"log"
+
+	"crud/api/http"
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register godoc
+// @ID register
+// @Router /register [POST]
+// @Summary Register User
+// @Description Register a client. Creating a super admin requires a super admin access token.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param user body models.CreateUser true "CreateUserRequestBody"
+// @Success 201 {object} http.Response{data=models.User} "GetUserBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 409 {object} http.Response{data=string} "Login Taken"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) Register(c *gin.Context) {
+	var user models.CreateUser
+
+	err := c.ShouldBindJSON(&user)
+	if err != nil {
+		h.handleBindingError(c, "register", err)
+		return
+	}
+
+	switch user.Role {
+	case "", h.cfg.Client:
+		user.Role = h.cfg.Client
+	case h.cfg.SuperAdmin:
+		claims, err := h.parseToken(c.GetHeader("Authorization"), accessTokenType)
+		if err != nil || claims["role"] != h.cfg.SuperAdmin {
+			log.Printf("error whiling register: super admin token required\n")
+			h.handleResponse(c, http.Forbidden, errors.New("permission denied").Error())
+			return
+		}
+	default:
+		log.Printf("error whiling register: unknown role %q\n", user.Role)
+		h.handleResponse(c, http.UnprocessableEntity, []http.FieldError{{
+			Field:  "role",
+			Reason: "must be one of " + h.cfg.Client + ", " + h.cfg.SuperAdmin,
+		}})
+		return
+	}
+
+	user.Password, err = helper.HashPassword(user.Password)
+	if err != nil {
+		h.handleError(c, "hash password", err)
+		return
+	}
+
+	id, err := h.storage.User().Create(c.Request.Context(), &user)
+	if err != nil {
+		h.handleError(c, "Create", err)
+		return
+	}
+
+	resp, err := h.storage.User().GetByPKey(
+		c.Request.Context(),
+		&models.UserPrimaryKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	h.handleResponse(c, http.Created, resp)
+}
+
+// Login godoc
+// @ID login
+// @Router /login [POST]
+// @Summary Login
+// @Description Exchange login and password for access and refresh tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param login body models.Login true "LoginRequestBody"
+// @Success 200 {object} http.Response{data=models.LoginResponse} "LoginResponseBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) Login(c *gin.Context) {
+	var login models.Login
+
+	err := c.ShouldBindJSON(&login)
+	if err != nil {
+		h.handleBindingError(c, "login", err)
+		return
+	}
+
+	user, err := h.storage.User().GetByPKey(
+		c.Request.Context(),
+		&models.UserPrimaryKey{Login: login.Login},
+	)
+
+	if storage.KindOf(err) == storage.KindNotFound || (err == nil && !helper.CheckPassword(user.Password, login.Password)) {
+		log.Printf("error whiling login: wrong login or password\n")
+		h.handleResponse(c, http.Unauthorized, errors.New("wrong login or password").Error())
+		return
+	}
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	resp, err := h.generateTokens(user)
+	if err != nil {
+		h.handleError(c, "generate tokens", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// RefreshToken godoc
+// @ID refresh_token
+// @Router /refresh [POST]
+// @Summary Refresh Token
+// @Description Exchange a refresh token for a new pair of tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshToken true "RefreshTokenRequestBody"
+// @Success 200 {object} http.Response{data=models.LoginResponse} "LoginResponseBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) RefreshToken(c *gin.Context) {
+	var refresh models.RefreshToken
+
+	err := c.ShouldBindJSON(&refresh)
+	if err != nil {
+		h.handleBindingError(c, "refresh", err)
+		return
+	}
+
+	claims, err := h.parseToken(refresh.RefreshToken, refreshTokenType)
+	if err != nil {
+		log.Printf("error whiling refresh: %v\n", err)
+		h.handleResponse(c, http.Unauthorized, errors.New("unauthorized").Error())
+		return
+	}
+
+	userId, _ := claims["id"].(string)
+
+	user, err := h.storage.User().GetByPKey(
+		c.Request.Context(),
+		&models.UserPrimaryKey{Id: userId},
+	)
+
+	if storage.KindOf(err) == storage.KindNotFound {
+		log.Printf("error whiling refresh: user %s not found\n", userId)
+		h.handleResponse(c, http.Unauthorized, errors.New("unauthorized").Error())
+		return
+	}
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	resp, err := h.generateTokens(user)
+	if err != nil {
+		h.handleError(c, "generate tokens", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"crud/api/http"
+	"crud/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// registerJSONFieldNames makes validation errors report the json names of
+// the fields, the names the client actually sent.
+func registerJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+}
+
+// registerDecimalType lets the numeric tags like gte=0 validate
+// decimal.Decimal amounts.
+func registerDecimalType() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		amount, _ := field.Interface().(decimal.Decimal).Float64()
+		return amount
+	}, decimal.Decimal{})
+}
+
+// registerHTTPURL adds the http_url tag: an absolute http or https URL to a
+// public host, the only kind a webhook can be delivered to.
+func registerHTTPURL() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	_ = v.RegisterValidation("http_url", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != "" && webhook.PublicHost(u.Hostname())
+	})
+}
+
+// handleBindingError answers 422 with every invalid field when the body
+// failed validation, and 400 when it is not valid JSON at all.
+func (h *HandlerV1) handleBindingError(c *gin.Context, message string, err error) {
+
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+	)
+
+	log.Printf("error whiling %s: %v\n", message, err)
+
+	switch {
+	case errors.As(err, &validationErrs):
+		h.handleResponse(c, http.UnprocessableEntity, fieldErrors(validationErrs))
+
+	case errors.As(err, &typeErr):
+		h.handleResponse(c, http.UnprocessableEntity, []http.FieldError{{
+			Field:  typeErr.Field,
+			Reason: "must be " + jsonType(typeErr.Type),
+		}})
+
+	default:
+		h.handleResponse(c, http.BadRequest, err.Error())
+	}
+}
+
+func fieldErrors(validationErrs validator.ValidationErrors) []http.FieldError {
+
+	fields := make([]http.FieldError, 0, len(validationErrs))
+
+	for _, fe := range validationErrs {
+		fields = append(fields, http.FieldError{
+			Field:  fieldPath(fe.Namespace()),
+			Reason: fieldReason(fe),
+		})
+	}
+
+	return fields
+}
+
+// patchField is implemented by models.Patch of any type.
+type patchField interface {
+	Present() bool
+	IsNull() bool
+	Get() interface{}
+}
+
+// validatePatch checks the supplied fields of a merge patch with the binding
+// tags of the full model: each one is copied onto the field of the same name
+// in full and validated there. null is only accepted on fields tagged
+// patch:"nullable".
+func validatePatch(patch, full interface{}) []http.FieldError {
+
+	var (
+		pv     = reflect.ValueOf(patch).Elem()
+		fv     = reflect.ValueOf(full).Elem()
+		fields []string
+		errs   []http.FieldError
+	)
+
+	for i := 0; i < pv.NumField(); i++ {
+		sf := pv.Type().Field(i)
+
+		field, ok := pv.Field(i).Interface().(patchField)
+		if !ok || !field.Present() {
+			continue
+		}
+
+		if field.IsNull() {
+			if sf.Tag.Get("patch") != "nullable" {
+				errs = append(errs, http.FieldError{
+					Field:  strings.SplitN(sf.Tag.Get("json"), ",", 2)[0],
+					Reason: "must not be null",
+				})
+			}
+			continue
+		}
+
+		fv.FieldByName(sf.Name).Set(reflect.ValueOf(field.Get()))
+		fields = append(fields, sf.Name)
+	}
+
+	if len(errs) > 0 || len(fields) == 0 {
+		return errs
+	}
+
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(v.StructPartial(full, fields...), &validationErrs) {
+		return fieldErrors(validationErrs)
+	}
+
+	return nil
+}
+
+// fieldPath drops the struct name from a namespace like
+// "CreateOrder.items[0].product_id".
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return namespace
+}
+
+func fieldReason(fe validator.FieldError) string {
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "uuid":
+		return "must be a valid UUID"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return "must contain at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param() + " characters long"
+	case "max":
+		return "must be at most " + fe.Param() + " characters long"
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "http_url":
+		return "must be an http or https URL to a public host"
+	}
+
+	return "is invalid"
+}
+
+func jsonType(t reflect.Type) string {
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+
+	return "an object"
+}
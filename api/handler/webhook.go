@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"log"
+
+	"crud/api/http"
+	"crud/models"
+	"crud/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhook godoc
+// @ID create_webhook
+// @Router /webhook [POST]
+// @Summary Create Webhook
+// @Description Registers an endpoint for the events. Every delivery is a POST of the event JSON,
+// @Description signed in X-Webhook-Signature with "sha256=" and the hex HMAC-SHA256 of "<X-Webhook-Timestamp>.<body>" keyed with the secret.
+// @Description Webhooks of clients only get the order events of their own orders.
+// @Description product.price_changed is sent when a change is made; a scheduled one carries scheduled: true and takes effect at its effective_from.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param webhook body models.CreateWebhook true "CreateWebhookRequestBody"
+// @Success 201 {object} http.Response{data=models.Webhook} "GetWebhookBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) CreateWebhook(c *gin.Context) {
+	var webhook models.CreateWebhook
+
+	err := c.ShouldBindJSON(&webhook)
+	if err != nil {
+		h.handleBindingError(c, "create", err)
+		return
+	}
+
+	webhook.UserID = c.GetString("user_id")
+	webhook.OwnerOnly = c.GetString("role") == h.cfg.Client
+
+	id, err := h.storage.Webhook().Create(c.Request.Context(), &webhook)
+	if err != nil {
+		h.handleError(c, "Create", err)
+		return
+	}
+
+	resp, err := h.storage.Webhook().GetByPKey(
+		c.Request.Context(),
+		&models.WebhookPrimaryKey{Id: id},
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	h.handleResponse(c, http.Created, resp)
+}
+
+// GetByIdWebhook godoc
+// @ID get_by_id_webhook
+// @Router /webhook/{id} [GET]
+// @Summary Get By Id Webhook
+// @Description Get By Id Webhook
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Success 200 {object} http.Response{data=models.Webhook} "GetWebhookBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetWebhookById(c *gin.Context) {
+
+	pkey, ok := h.webhookPKey(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storage.Webhook().GetByPKey(
+		c.Request.Context(),
+		pkey,
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// GetListWebhook godoc
+// @ID get_list_webhook
+// @Router /webhook [GET]
+// @Summary Get List Webhook
+// @Description Get List Webhook
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Success 200 {object} http.Response{data=models.GetListWebhookResponse} "GetWebhookBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetWebhookList(c *gin.Context) {
+
+	limit, offset, err := parseLimitOffset(c)
+	if err != nil {
+		log.Printf("error whiling page: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.storage.Webhook().GetList(
+		c.Request.Context(),
+		&models.GetListWebhookRequest{
+			UserID: h.webhookOwner(c),
+			Limit:  limit,
+			Offset: offset,
+		},
+	)
+
+	if err != nil {
+		h.handleError(c, "get list", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// UpdateWebhook godoc
+// @ID update_webhook
+// @Router /webhook/{id} [PUT]
+// @Summary Update Webhook
+// @Description Replaces the url and events. An empty secret keeps the current one, and active true turns a disabled webhook back on.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param webhook body models.UpdateWebhookSwagger true "UpdateWebhookRequestBody"
+// @Success 200 {object} http.Response{data=models.Webhook} "GetWebhookBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 422 {object} http.Response{data=[]http.FieldError} "Invalid Fields"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) UpdateWebhook(c *gin.Context) {
+
+	var (
+		webhook models.UpdateWebhook
+	)
+
+	pkey, ok := h.webhookPKey(c)
+	if !ok {
+		return
+	}
+
+	err := c.ShouldBindJSON(&webhook)
+	if err != nil {
+		h.handleBindingError(c, "update", err)
+		return
+	}
+
+	webhook.Id = pkey.Id
+	webhook.UserID = pkey.UserID
+
+	rowsAffected, err := h.storage.Webhook().Update(
+		c.Request.Context(),
+		&webhook,
+	)
+
+	if err != nil {
+		h.handleError(c, "update", err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		h.handleError(c, "update rows affected", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storage.Webhook().GetByPKey(
+		c.Request.Context(),
+		pkey,
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// DeleteWebhook godoc
+// @ID delete_webhook
+// @Router /webhook/{id} [DELETE]
+// @Summary Delete Webhook
+// @Description Delete Webhook, its pending deliveries are dropped
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Success 204 {object} http.Response{data=string} "No Content"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) DeleteWebhook(c *gin.Context) {
+
+	pkey, ok := h.webhookPKey(c)
+	if !ok {
+		return
+	}
+
+	err := h.storage.Webhook().Delete(
+		c.Request.Context(),
+		pkey,
+	)
+
+	if err != nil {
+		h.handleError(c, "delete", err)
+		return
+	}
+
+	h.handleResponse(c, http.NoContent, nil)
+}
+
+// GetWebhookDeliveries godoc
+// @ID get_webhook_deliveries
+// @Router /webhook/{id}/deliveries [GET]
+// @Summary Get Webhook Deliveries
+// @Description The delivery log of the webhook, newest first, with the status code of the latest attempt
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "id"
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Success 200 {object} http.Response{data=models.GetListWebhookDeliveryResponse} "GetWebhookDeliveriesBody"
+// @Response 400 {object} http.Response{data=string} "Invalid Argument"
+// @Failure 401 {object} http.Response{data=string} "Unauthorized"
+// @Failure 403 {object} http.Response{data=string} "Permission Denied"
+// @Failure 404 {object} http.Response{data=string} "Not Found"
+// @Failure 500 {object} http.Response{data=string} "Server Error"
+func (h *HandlerV1) GetWebhookDeliveries(c *gin.Context) {
+
+	limit, offset, err := parseLimitOffset(c)
+	if err != nil {
+		log.Printf("error whiling page: %v\n", err)
+		h.handleResponse(c, http.BadRequest, err.Error())
+		return
+	}
+
+	pkey, ok := h.webhookPKey(c)
+	if !ok {
+		return
+	}
+
+	// the lookup keeps clients to the logs of their own webhooks
+	_, err = h.storage.Webhook().GetByPKey(
+		c.Request.Context(),
+		pkey,
+	)
+
+	if err != nil {
+		h.handleError(c, "GetByPKey", err)
+		return
+	}
+
+	resp, err := h.storage.Webhook().GetDeliveries(
+		c.Request.Context(),
+		&models.GetListWebhookDeliveryRequest{
+			WebhookID: pkey.Id,
+			Limit:     limit,
+			Offset:    offset,
+		},
+	)
+
+	if err != nil {
+		h.handleError(c, "get deliveries", err)
+		return
+	}
+
+	h.handleResponse(c, http.OK, resp)
+}
+
+// webhookPKey scopes the webhook in the path to the caller, clients only
+// see their own webhooks. It answers 400 itself when the id is invalid.
+func (h *HandlerV1) webhookPKey(c *gin.Context) (*models.WebhookPrimaryKey, bool) {
+
+	id, ok := h.pathID(c)
+	if !ok {
+		return nil, false
+	}
+
+	return &models.WebhookPrimaryKey{Id: id, UserID: h.webhookOwner(c)}, true
+}
+
+// webhookOwner is the user the webhooks of the caller are limited to, empty
+// for admins who see them all.
+func (h *HandlerV1) webhookOwner(c *gin.Context) string {
+
+	if c.GetString("role") == h.cfg.Client {
+		return c.GetString("user_id")
+	}
+
+	return ""
+}
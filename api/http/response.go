@@ -6,3 +6,9 @@ type Response struct {
 	Description string      `json:"description"`
 	Data        interface{} `json:"data"`
 }
+
+// FieldError ...
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,71 @@
+package http
+
+// Status ...
+type Status struct {
+	Code        int
+	Status      string
+	Description string
+}
+
+var (
+	OK = Status{
+		Code:        200,
+		Status:      "OK",
+		Description: "The request has succeeded",
+	}
+	Created = Status{
+		Code:        201,
+		Status:      "CREATED",
+		Description: "The request has succeeded and a new resource has been created as a result",
+	}
+	NoContent = Status{
+		Code:        204,
+		Status:      "NO_CONTENT",
+		Description: "There is no content to send for this request",
+	}
+	BadRequest = Status{
+		Code:        400,
+		Status:      "BAD_REQUEST",
+		Description: "The server could not understand the request due to invalid syntax",
+	}
+	Unauthorized = Status{
+		Code:        401,
+		Status:      "UNAUTHORIZED",
+		Description: "The client must authenticate itself to get the requested response",
+	}
+	Forbidden = Status{
+		Code:        403,
+		Status:      "FORBIDDEN",
+		Description: "The client does not have access rights to the content",
+	}
+	NotFound = Status{
+		Code:        404,
+		Status:      "NOT_FOUND",
+		Description: "The server can not find the requested resource",
+	}
+	Conflict = Status{
+		Code:        409,
+		Status:      "CONFLICT",
+		Description: "The request conflicts with the current state of the server",
+	}
+	PreconditionFailed = Status{
+		Code:        412,
+		Status:      "PRECONDITION_FAILED",
+		Description: "The resource was modified since the version given in If-Match",
+	}
+	UnprocessableEntity = Status{
+		Code:        422,
+		Status:      "UNPROCESSABLE_ENTITY",
+		Description: "The request was well-formed but was unable to be followed due to semantic errors",
+	}
+	InternalServerError = Status{
+		Code:        500,
+		Status:      "INTERNAL_SERVER_ERROR",
+		Description: "The server has encountered a situation it doesn't know how to handle",
+	}
+	ServiceUnavailable = Status{
+		Code:        503,
+		Status:      "SERVICE_UNAVAILABLE",
+		Description: "The server is not ready to handle the request",
+	}
+)
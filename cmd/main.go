@@ -4,31 +4,221 @@ import (
 	"context"
 	"crud/api"
 	"crud/config"
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/pkg/metrics"
+	"crud/pkg/outbox"
+	"crud/pkg/webhook"
+	"crud/storage"
+	"crud/storage/cache"
+	"crud/storage/memory"
 	"crud/storage/postgres"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 
-	cfg := config.Load()
+	// migrate needs the database only, not the secrets of the service
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(context.Background(), os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("config: %s\n", cfg)
 
 	r := gin.New()
 
-	r.Use(gin.Logger(), gin.Recovery())
+	// metrics sits outside Recovery so panics are counted as 500s
+	r.Use(gin.Logger(), metrics.Middleware(), gin.Recovery())
+
+	var store storage.StorageI
+
+	switch cfg.StorageType {
+	case config.StorageMemory:
+		store = memory.NewMemory()
+	case config.StoragePostgres:
+		store, err = postgres.NewPostgres(context.Background(), cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown storage type: %s", cfg.StorageType)
+	}
+
+	if cfg.CacheType != config.CacheNone {
+		store = cache.NewCache(cfg, store, cache.NewBackend(context.Background(), cfg))
+	}
+
+	err = seedSuperAdmin(context.Background(), cfg, store)
+	if err != nil {
+		store.CloseDB()
+		log.Fatal(err)
+	}
+
+	api.SetUpApi(&cfg, r, store)
+
+	stopDispatcher := startDispatcher(cfg, store)
+
+	srv := &http.Server{
+		Addr:    cfg.HTTPPort,
+		Handler: r,
+	}
+
+	err = serve(srv, cfg.ShutdownTimeout)
+
+	// the pool is closed only after the in-flight requests and the
+	// background jobs are done with it
+	stopDispatcher()
+	store.CloseDB()
 
-	storage, err := postgres.NewPostgres(context.Background(), cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer storage.CloseDB()
+}
+
+// serve runs the server until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to timeout for the in-flight requests.
+func serve(srv *http.Server, timeout time.Duration) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		log.Printf("Listening port %v...\n", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	// a second signal kills the process the default way
+	stop()
+
+	log.Printf("shutting down, draining requests for up to %s\n", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	log.Println("server stopped")
+
+	return nil
+}
+
+// idempotencyPurgeInterval is how often the expired idempotency keys are
+// dropped.
+const idempotencyPurgeInterval = time.Hour
+
+// startDispatcher runs the outbox dispatcher, the webhook deliverer and the
+// purge of the expired idempotency keys in the background. The returned func
+// stops them all and waits for them to return.
+func startDispatcher(cfg config.Config, store storage.StorageI) func() {
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          sync.WaitGroup
+		sinks       = append(outbox.Sinks(cfg), webhook.NewFanout(store.Webhook()))
+		dispatcher  = outbox.NewDispatcher(cfg, store.Outbox(), sinks...)
+		deliverer   = webhook.NewDeliverer(cfg, store.Webhook(), nil)
+	)
+
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		deliverer.Run(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		purgeIdempotencyKeys(ctx, store.Idempotency())
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// purgeIdempotencyKeys drops the expired idempotency keys every
+// idempotencyPurgeInterval until ctx is done.
+func purgeIdempotencyKeys(ctx context.Context, repo storage.IdempotencyRepoI) {
+
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
 
-	api.SetUpApi(&cfg, r, storage)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := repo.PurgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("error whiling purge idempotency keys: %v\n", err)
+		}
+
+		if n > 0 {
+			log.Printf("purged %d expired idempotency keys\n", n)
+		}
+	}
+}
 
-	log.Printf("Listening port %v...\n", cfg.HTTPPort)
-	err = r.Run(cfg.HTTPPort)
+// seedSuperAdmin creates the configured super admin unless the login is
+// already taken.
+func seedSuperAdmin(ctx context.Context, cfg config.Config, store storage.StorageI) error {
+
+	if cfg.SuperAdminLogin == "" || cfg.SuperAdminPassword == "" {
+		return nil
+	}
+
+	_, err := store.User().GetByPKey(ctx, &models.UserPrimaryKey{Login: cfg.SuperAdminLogin})
+	if storage.KindOf(err) != storage.KindNotFound {
+		return err
+	}
+
+	password, err := helper.HashPassword(cfg.SuperAdminPassword)
 	if err != nil {
-		panic(err)
+		return err
 	}
+
+	_, err = store.User().Create(ctx, &models.CreateUser{
+		Login:    cfg.SuperAdminLogin,
+		Password: password,
+		Role:     cfg.SuperAdmin,
+	})
+
+	return err
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"crud/config"
+	"crud/storage/postgres"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | goto N"
+
+// runMigrate handles `main migrate ...`, applying the migrations embedded
+// in the binary. It reads only the Postgres settings.
+func runMigrate(ctx context.Context, args []string) error {
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	cfg, err := config.LoadPostgres()
+	if err != nil {
+		return err
+	}
+
+	pool, err := postgres.NewPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := postgres.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			var convErr error
+			steps, convErr = strconv.Atoi(args[1])
+			if convErr != nil || steps <= 0 {
+				return errors.New("down takes a positive number of steps")
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case args[0] == "goto" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			return errors.New("goto takes a migration version, 0 for an empty schema")
+		}
+		err = migrator.Goto(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return printMigrationStatus(ctx, migrator)
+}
+
+func printMigrationStatus(ctx context.Context, migrator *postgres.Migrator) error {
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("schema version %d of %d, dirty: %t\n", version, migrator.Latest(), dirty)
+
+	for _, mig := range migrator.Migrations() {
+		state := "pending"
+		if mig.Version <= version {
+			state = "applied"
+		}
+
+		fmt.Printf("%02d_%s\t%s\n", mig.Version, mig.Name, state)
+	}
+
+	return nil
+}
@@ -1,36 +1,383 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
 type Config struct {
 	HTTPPort string
 
+	// ShutdownTimeout bounds how long in-flight requests may drain on SIGTERM
+	ShutdownTimeout time.Duration
+
+	// IdempotencyTTL is how long a response stays replayable under its
+	// Idempotency-Key
+	IdempotencyTTL time.Duration
+
+	// StorageType selects the storage.StorageI backend: "postgres" or "memory"
+	StorageType string
+
 	PostgresHost           string
 	PostgresUser           string
 	PostgresDatabase       string
 	PostgresPassword       string
 	PostgresPort           string
+	PostgresSSLMode        string
 	PostgresMaxConnections int32
+	PostgresMinConnections int32
+
+	PostgresMaxConnLifetime   time.Duration
+	PostgresHealthCheckPeriod time.Duration
+
+	// MigrateOnStartup applies the embedded migrations before serving
+	MigrateOnStartup bool
 
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
 
+	// CacheType selects the read-through cache in front of the storage:
+	// "none", "redis" or "lru". A redis cache that cannot be reached at
+	// startup falls back to the in-process LRU.
+	CacheType string
+	// CacheTTL bounds how long a product or category stays cached,
+	// CacheTreeTTL the same for the category tree
+	CacheTTL     time.Duration
+	CacheTreeTTL time.Duration
+	// CacheSize is the number of entries the LRU keeps
+	CacheSize int
+
+	// OutboxStdout and OutboxWebhookURL add sinks the outbox events are
+	// delivered to, next to the webhooks registered under /webhook
+	OutboxStdout     bool
+	OutboxWebhookURL string
+	// OutboxPollInterval is how often the dispatcher looks for due events
+	// and OutboxBatchSize how many it claims at once
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// OutboxMaxAttempts bounds the deliveries of an event; the wait between
+	// them doubles from OutboxRetryBackoff
+	OutboxMaxAttempts  int
+	OutboxRetryBackoff time.Duration
+
+	// WebhookTimeout bounds one delivery to a registered webhook. A delivery
+	// is tried up to WebhookMaxAttempts times, the wait doubling from
+	// WebhookRetryBackoff, and a webhook is disabled after
+	// WebhookDisableAfter failed attempts in a row
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookRetryBackoff time.Duration
+	WebhookDisableAfter int
+
+	// ImportMaxRows bounds the rows of one product or category import
+	ImportMaxRows int
+
+	// AuthSecretKey signs the tokens; it has no default and must be at
+	// least MinAuthSecretKeyLength characters
 	AuthSecretKey string
 	SuperAdmin    string
 	Client        string
+
+	// SuperAdminLogin and SuperAdminPassword seed the first super admin
+	SuperAdminLogin    string
+	SuperAdminPassword string
 }
 
-func Load() Config {
+// Load reads the configuration from the environment. Values missing there
+// are taken from the file named by CONFIG_FILE (YAML or .env) or from ./.env,
+// and fall back to the defaults below.
+func Load() (Config, error) {
 
 	var cfg Config
 
-	cfg.HTTPPort = ":4000"
+	src, err := newSource()
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.HTTPPort = src.String("HTTP_PORT", ":4000")
+	if !strings.Contains(cfg.HTTPPort, ":") {
+		cfg.HTTPPort = ":" + cfg.HTTPPort
+	}
+
+	cfg.ShutdownTimeout = src.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	cfg.IdempotencyTTL = src.Duration("IDEMPOTENCY_TTL", 24*time.Hour)
+
+	cfg.StorageType = src.String("STORAGE_TYPE", StoragePostgres)
+
+	loadPostgres(src, &cfg)
+	cfg.MigrateOnStartup = src.Bool("MIGRATE_ON_STARTUP", false)
+
+	cfg.RedisAddr = src.String("REDIS_ADDR", "localhost:6379")
+	cfg.RedisPassword = src.String("REDIS_PASSWORD", "")
+	cfg.RedisDB = src.Int("REDIS_DB", 0)
+
+	cfg.CacheType = src.String("CACHE_TYPE", CacheNone)
+	cfg.CacheTTL = src.Duration("CACHE_TTL", 5*time.Minute)
+	cfg.CacheTreeTTL = src.Duration("CACHE_TREE_TTL", time.Minute)
+	cfg.CacheSize = src.Int("CACHE_SIZE", 10000)
+
+	cfg.OutboxStdout = src.Bool("OUTBOX_STDOUT", false)
+	cfg.OutboxWebhookURL = src.String("OUTBOX_WEBHOOK_URL", "")
+	cfg.OutboxPollInterval = src.Duration("OUTBOX_POLL_INTERVAL", time.Second)
+	cfg.OutboxBatchSize = src.Int("OUTBOX_BATCH_SIZE", 100)
+	cfg.OutboxMaxAttempts = src.Int("OUTBOX_MAX_ATTEMPTS", 10)
+	cfg.OutboxRetryBackoff = src.Duration("OUTBOX_RETRY_BACKOFF", time.Second)
+
+	cfg.WebhookTimeout = src.Duration("WEBHOOK_TIMEOUT", 10*time.Second)
+	cfg.WebhookMaxAttempts = src.Int("WEBHOOK_MAX_ATTEMPTS", 8)
+	cfg.WebhookRetryBackoff = src.Duration("WEBHOOK_RETRY_BACKOFF", 5*time.Second)
+	cfg.WebhookDisableAfter = src.Int("WEBHOOK_DISABLE_AFTER", 20)
+
+	cfg.ImportMaxRows = src.Int("IMPORT_MAX_ROWS", 10000)
+
+	cfg.AuthSecretKey = src.String("AUTH_SECRET_KEY", "")
+
+	cfg.SuperAdmin = "SUPER_ADMIN"
+	cfg.Client = "CLIENT"
+
+	cfg.SuperAdminLogin = src.String("SUPER_ADMIN_LOGIN", "")
+	cfg.SuperAdminPassword = src.String("SUPER_ADMIN_PASSWORD", "")
+
+	errs := append(src.errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return cfg, errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+
+	return cfg, nil
+}
+
+// LoadPostgres reads only the Postgres settings, for the migrate command
+// that runs from deploy jobs without the secrets of the service.
+func LoadPostgres() (Config, error) {
+
+	cfg := Config{StorageType: StoragePostgres}
+
+	src, err := newSource()
+	if err != nil {
+		return cfg, err
+	}
+
+	loadPostgres(src, &cfg)
+
+	errs := append(src.errs, cfg.validatePostgres()...)
+	if len(errs) > 0 {
+		return cfg, errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+
+	return cfg, nil
+}
+
+// loadPostgres reads the connection and pool settings into cfg.
+func loadPostgres(src *source, cfg *Config) {
+	cfg.PostgresHost = src.String("POSTGRES_HOST", "localhost")
+	cfg.PostgresUser = src.String("POSTGRES_USER", "jahongir")
+	cfg.PostgresDatabase = src.String("POSTGRES_DATABASE", "h_database")
+	cfg.PostgresPassword = src.String("POSTGRES_PASSWORD", "00")
+	cfg.PostgresPort = src.String("POSTGRES_PORT", "5432")
+	cfg.PostgresSSLMode = src.String("POSTGRES_SSLMODE", "disable")
+	cfg.PostgresMaxConnections = int32(src.Int("POSTGRES_MAX_CONNECTIONS", 20))
+	cfg.PostgresMinConnections = int32(src.Int("POSTGRES_MIN_CONNECTIONS", 0))
+	cfg.PostgresMaxConnLifetime = src.Duration("POSTGRES_MAX_CONN_LIFETIME", time.Hour)
+	cfg.PostgresHealthCheckPeriod = src.Duration("POSTGRES_HEALTH_CHECK_PERIOD", time.Minute)
+}
+
+// Validate lists the settings the service cannot start with.
+func (c Config) Validate() []string {
+
+	var errs []string
+
+	if c.HTTPPort == ":" {
+		errs = append(errs, "HTTP_PORT is required")
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, "SHUTDOWN_TIMEOUT must be greater than 0")
+	}
+
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, "IDEMPOTENCY_TTL must be greater than 0")
+	}
+
+	// the key signs every token, a short one can be brute forced offline
+	switch {
+	case c.AuthSecretKey == SampleAuthSecretKey:
+		errs = append(errs, "AUTH_SECRET_KEY must not be the sample key, generate one with: openssl rand -hex 32")
+	case len(c.AuthSecretKey) < MinAuthSecretKeyLength:
+		errs = append(errs, fmt.Sprintf("AUTH_SECRET_KEY must be at least %d characters long", MinAuthSecretKeyLength))
+	}
+
+	if (c.SuperAdminLogin == "") != (c.SuperAdminPassword == "") {
+		errs = append(errs, "SUPER_ADMIN_LOGIN and SUPER_ADMIN_PASSWORD must be set together")
+	}
+
+	switch c.CacheType {
+	case CacheNone:
+	case CacheRedis, CacheLRU:
+		if c.CacheTTL <= 0 {
+			errs = append(errs, "CACHE_TTL must be greater than 0")
+		}
+
+		if c.CacheTreeTTL <= 0 {
+			errs = append(errs, "CACHE_TREE_TTL must be greater than 0")
+		}
+
+		if c.CacheSize <= 0 {
+			errs = append(errs, "CACHE_SIZE must be greater than 0")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("CACHE_TYPE must be %s, %s or %s", CacheNone, CacheRedis, CacheLRU))
+	}
+
+	if c.OutboxWebhookURL != "" {
+		u, err := url.Parse(c.OutboxWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, "OUTBOX_WEBHOOK_URL must be an http or https URL")
+		}
+	}
+
+	if c.OutboxPollInterval <= 0 {
+		errs = append(errs, "OUTBOX_POLL_INTERVAL must be greater than 0")
+	}
+
+	if c.OutboxBatchSize <= 0 {
+		errs = append(errs, "OUTBOX_BATCH_SIZE must be greater than 0")
+	}
+
+	if c.OutboxMaxAttempts <= 0 {
+		errs = append(errs, "OUTBOX_MAX_ATTEMPTS must be greater than 0")
+	}
+
+	if c.OutboxRetryBackoff <= 0 {
+		errs = append(errs, "OUTBOX_RETRY_BACKOFF must be greater than 0")
+	}
+
+	if c.WebhookTimeout <= 0 {
+		errs = append(errs, "WEBHOOK_TIMEOUT must be greater than 0")
+	}
+
+	if c.WebhookMaxAttempts <= 0 {
+		errs = append(errs, "WEBHOOK_MAX_ATTEMPTS must be greater than 0")
+	}
+
+	if c.WebhookRetryBackoff <= 0 {
+		errs = append(errs, "WEBHOOK_RETRY_BACKOFF must be greater than 0")
+	}
+
+	if c.WebhookDisableAfter <= 0 {
+		errs = append(errs, "WEBHOOK_DISABLE_AFTER must be greater than 0")
+	}
+
+	if c.ImportMaxRows <= 0 {
+		errs = append(errs, "IMPORT_MAX_ROWS must be greater than 0")
+	}
+
+	switch c.StorageType {
+	case StorageMemory:
+	case StoragePostgres:
+		errs = append(errs, c.validatePostgres()...)
+	default:
+		errs = append(errs, fmt.Sprintf("STORAGE_TYPE must be %s or %s", StoragePostgres, StorageMemory))
+	}
+
+	return errs
+}
+
+// validatePostgres lists the Postgres settings a pool cannot be opened with.
+func (c Config) validatePostgres() []string {
+
+	var errs []string
+
+	if c.PostgresHost == "" {
+		errs = append(errs, "POSTGRES_HOST is required")
+	}
+
+	if c.PostgresPort == "" {
+		errs = append(errs, "POSTGRES_PORT is required")
+	}
+
+	if c.PostgresUser == "" {
+		errs = append(errs, "POSTGRES_USER is required")
+	}
+
+	if c.PostgresDatabase == "" {
+		errs = append(errs, "POSTGRES_DATABASE is required")
+	}
+
+	if !sslModes[c.PostgresSSLMode] {
+		errs = append(errs, "POSTGRES_SSLMODE must be one of disable, allow, prefer, require, verify-ca, verify-full")
+	}
+
+	if c.PostgresMaxConnections <= 0 {
+		errs = append(errs, "POSTGRES_MAX_CONNECTIONS must be greater than 0")
+	}
+
+	if c.PostgresMinConnections < 0 || c.PostgresMinConnections > c.PostgresMaxConnections {
+		errs = append(errs, "POSTGRES_MIN_CONNECTIONS must be between 0 and POSTGRES_MAX_CONNECTIONS")
+	}
+
+	if c.PostgresMaxConnLifetime <= 0 {
+		errs = append(errs, "POSTGRES_MAX_CONN_LIFETIME must be greater than 0")
+	}
+
+	if c.PostgresHealthCheckPeriod <= 0 {
+		errs = append(errs, "POSTGRES_HEALTH_CHECK_PERIOD must be greater than 0")
+	}
+
+	return errs
+}
+
+// PostgresURL builds the connection string for pgxpool.ParseConfig.
+func (c Config) PostgresURL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.PostgresUser, c.PostgresPassword),
+		Host:     c.PostgresHost + ":" + c.PostgresPort,
+		Path:     c.PostgresDatabase,
+		RawQuery: "sslmode=" + url.QueryEscape(c.PostgresSSLMode),
+	}
+
+	return u.String()
+}
+
+// String prints the config with the secrets redacted, so it is safe to log.
+func (c Config) String() string {
+	return fmt.Sprintf(
+		"http_port=%s shutdown_timeout=%s idempotency_ttl=%s storage_type=%s postgres=%s:%s/%s user=%s password=%s sslmode=%s "+
+			"max_conns=%d min_conns=%d max_conn_lifetime=%s health_check_period=%s migrate_on_startup=%t "+
+			"redis=%s/%d redis_password=%s cache_type=%s cache_ttl=%s cache_tree_ttl=%s cache_size=%d "+
+			"outbox_stdout=%t outbox_webhook_url=%s outbox_poll_interval=%s outbox_batch_size=%d outbox_max_attempts=%d outbox_retry_backoff=%s "+
+			"webhook_timeout=%s webhook_max_attempts=%d webhook_retry_backoff=%s webhook_disable_after=%d import_max_rows=%d "+
+			"auth_secret_key=%s super_admin_login=%s super_admin_password=%s",
+		c.HTTPPort, c.ShutdownTimeout, c.IdempotencyTTL, c.StorageType, c.PostgresHost, c.PostgresPort, c.PostgresDatabase,
+		c.PostgresUser, redact(c.PostgresPassword), c.PostgresSSLMode,
+		c.PostgresMaxConnections, c.PostgresMinConnections, c.PostgresMaxConnLifetime, c.PostgresHealthCheckPeriod, c.MigrateOnStartup,
+		c.RedisAddr, c.RedisDB, redact(c.RedisPassword),
+		c.CacheType, c.CacheTTL, c.CacheTreeTTL, c.CacheSize,
+		c.OutboxStdout, c.OutboxWebhookURL, c.OutboxPollInterval, c.OutboxBatchSize, c.OutboxMaxAttempts, c.OutboxRetryBackoff,
+		c.WebhookTimeout, c.WebhookMaxAttempts, c.WebhookRetryBackoff, c.WebhookDisableAfter, c.ImportMaxRows,
+		redact(c.AuthSecretKey),
+		c.SuperAdminLogin, redact(c.SuperAdminPassword),
+	)
+}
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
 
-	cfg.PostgresHost = "localhost"
-	cfg.PostgresUser = "jahongir"
-	cfg.PostgresDatabase = "h_database"
-	cfg.PostgresPassword = "00"
-	cfg.PostgresPort = "5432"
-	cfg.PostgresMaxConnections = 20
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
 
-	return cfg
+	return "***"
 }
@@ -2,7 +2,29 @@ package config
 
 import "time"
 
+// AccessTokenTTL is the lifetime of the access tokens of every role, kept
+// short since they cannot be revoked; RefreshTokenTTL that of the refresh
+// tokens used to get new ones.
 const (
-	TimeExpiredAt      = time.Hour * 24
-	SuperTimeExpiredAt = time.Minute * 2
+	AccessTokenTTL  = time.Minute * 2
+	RefreshTokenTTL = time.Hour * 24
+)
+
+// MinAuthSecretKeyLength is the shortest AUTH_SECRET_KEY the server starts
+// with; there is no default key.
+const MinAuthSecretKeyLength = 32
+
+// SampleAuthSecretKey is the key older versions shipped as the default; it
+// is public, so it is refused whatever its length.
+const SampleAuthSecretKey = "crud_secret_key"
+
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+const (
+	CacheNone  = "none"
+	CacheRedis = "redis"
+	CacheLRU   = "lru"
 )
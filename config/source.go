@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// source resolves a setting from the environment first and the config file
+// second. Values that fail to parse are collected in errs.
+type source struct {
+	file map[string]string
+	errs []string
+}
+
+func newSource() (*source, error) {
+
+	var (
+		src  = &source{file: map[string]string{}}
+		path = os.Getenv("CONFIG_FILE")
+	)
+
+	if path == "" {
+		if _, err := os.Stat(".env"); err != nil {
+			return src, nil
+		}
+		path = ".env"
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = parseYAML(data, src.file)
+	default:
+		err = parseDotEnv(data, src.file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return src, nil
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+
+	value, ok := s.file[key]
+	return value, ok
+}
+
+func (s *source) String(key, def string) string {
+	if value, ok := s.lookup(key); ok {
+		return value
+	}
+
+	return def
+}
+
+func (s *source) Int(key string, def int) int {
+	value, ok := s.lookup(key)
+	if !ok || value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		s.errs = append(s.errs, key+" must be an integer")
+		return def
+	}
+
+	return n
+}
+
+func (s *source) Duration(key string, def time.Duration) time.Duration {
+	value, ok := s.lookup(key)
+	if !ok || value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		s.errs = append(s.errs, key+" must be a duration like 30s or 1h")
+		return def
+	}
+
+	return d
+}
+
+func (s *source) Bool(key string, def bool) bool {
+	value, ok := s.lookup(key)
+	if !ok || value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		s.errs = append(s.errs, key+" must be true or false")
+		return def
+	}
+
+	return b
+}
+
+// parseYAML reads a flat mapping. Keys are the environment variable names,
+// in any case: postgres_host works as well as POSTGRES_HOST.
+func parseYAML(data []byte, values map[string]string) error {
+
+	var doc map[string]interface{}
+
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range doc {
+		if value == nil {
+			continue
+		}
+		values[strings.ToUpper(key)] = fmt.Sprint(value)
+	}
+
+	return nil
+}
+
+// parseDotEnv reads KEY=VALUE lines, skipping blanks and # comments.
+func parseDotEnv(data []byte, values map[string]string) error {
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		values[strings.TrimSpace(key)] = value
+	}
+
+	return scanner.Err()
+}
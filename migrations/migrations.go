@@ -0,0 +1,8 @@
+// Package migrations embeds the schema migrations so the binary can apply
+// them without the migrate CLI.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql
+var Postgres embed.FS
@@ -0,0 +1,11 @@
+ALTER TABLE orders ADD COLUMN product_id UUID REFERENCES products(id);
+
+UPDATE orders SET product_id = (
+    SELECT order_items.product_id
+    FROM order_items
+    WHERE order_items.order_id = orders.id
+    ORDER BY order_items.created_at
+    LIMIT 1
+);
+
+DROP TABLE IF EXISTS order_items;
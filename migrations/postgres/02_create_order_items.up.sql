@@ -0,0 +1,17 @@
+CREATE TABLE order_items (
+    id UUID PRIMARY KEY NOT NULL,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    product_id UUID NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    price NUMERIC NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX order_items_order_id_idx ON order_items(order_id);
+
+INSERT INTO order_items (id, order_id, product_id, quantity, price)
+SELECT orders.id, orders.id, orders.product_id, 1, products.price
+FROM orders
+JOIN products ON orders.product_id = products.id;
+
+ALTER TABLE orders DROP COLUMN product_id;
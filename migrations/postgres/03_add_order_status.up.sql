@@ -0,0 +1,16 @@
+ALTER TABLE orders ADD COLUMN status VARCHAR NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
+
+CREATE TABLE order_status_history (
+    id UUID PRIMARY KEY NOT NULL,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    from_status VARCHAR,
+    to_status VARCHAR NOT NULL,
+    comment VARCHAR,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX order_status_history_order_id_idx ON order_status_history(order_id);
+
+INSERT INTO order_status_history (id, order_id, to_status, created_at)
+SELECT id, id, status, created_at FROM orders;
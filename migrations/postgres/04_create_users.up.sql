@@ -0,0 +1,13 @@
+CREATE TABLE users (
+    id UUID PRIMARY KEY NOT NULL,
+    login VARCHAR NOT NULL UNIQUE,
+    password VARCHAR NOT NULL,
+    role VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+ALTER TABLE orders ADD COLUMN user_id UUID REFERENCES users(id);
+
+CREATE INDEX orders_user_id_idx ON orders(user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_parent_id_idx;
+
+DROP INDEX IF EXISTS products_created_at_idx;
+DROP INDEX IF EXISTS products_category_id_idx;
+
+DROP INDEX IF EXISTS products_name_trgm_idx;
+DROP INDEX IF EXISTS products_name_tsv_idx;
@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX products_name_tsv_idx ON products USING GIN (to_tsvector('simple', name));
+CREATE INDEX products_name_trgm_idx ON products USING GIN (name gin_trgm_ops);
+
+CREATE INDEX products_category_id_idx ON products(category_id);
+CREATE INDEX products_created_at_idx ON products(created_at);
+
+CREATE INDEX categories_parent_id_idx ON categories(parent_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inventory_movements;
+
+ALTER TABLE products DROP COLUMN IF EXISTS stock_quantity;
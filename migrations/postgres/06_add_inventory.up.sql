@@ -0,0 +1,14 @@
+ALTER TABLE products ADD COLUMN stock_quantity INTEGER NOT NULL DEFAULT 0
+    CHECK (stock_quantity >= 0);
+
+CREATE TABLE inventory_movements (
+    id UUID PRIMARY KEY NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id),
+    order_id UUID REFERENCES orders(id),
+    quantity INTEGER NOT NULL,
+    reason VARCHAR NOT NULL CHECK (reason IN ('restock', 'reserve', 'release')),
+    comment VARCHAR,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX inventory_movements_product_id_idx ON inventory_movements(product_id, created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS orders_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS orders_created_at_id_idx;
+DROP INDEX IF EXISTS categories_created_at_id_idx;
+DROP INDEX IF EXISTS products_created_at_id_idx;
+
+CREATE INDEX products_created_at_idx ON products(created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS products_created_at_idx;
+
+CREATE INDEX products_created_at_id_idx ON products(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX categories_created_at_id_idx ON categories(created_at, id) WHERE parent_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX orders_created_at_id_idx ON orders(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX orders_user_id_created_at_id_idx ON orders(user_id, created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE product_prices (
+    id UUID PRIMARY KEY NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id),
+    price NUMERIC NOT NULL CHECK (price >= 0),
+    effective_from TIMESTAMP NOT NULL,
+    effective_to TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CHECK (effective_to IS NULL OR effective_to > effective_from),
+    UNIQUE (product_id, effective_from)
+);
+
+INSERT INTO product_prices (id, product_id, price, effective_from)
+SELECT id, id, price, created_at FROM products;
@@ -0,0 +1,5 @@
+ALTER TABLE categories ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE orders ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -5,8 +5,8 @@ type CategoryPrimaryKey struct {
 }
 
 type CreateCategory struct {
-	Name     string `json:"name"`
-	ParentID string `json:"parent_id"`
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentID string `json:"parent_id" binding:"omitempty,uuid"`
 }
 
 type Category struct {
@@ -18,31 +18,73 @@ type Category struct {
 }
 
 type UpdateCategorySwagger struct {
-	Name     string `json:"name"`
-	ParentID string `json:"parent_id"`
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentID string `json:"parent_id" binding:"omitempty,uuid"`
 }
 
 type UpdateCategory struct {
 	Id       string `json:"id"`
-	Name     string `json:"name"`
-	ParentID string `json:"parent_id"`
+	Version  int    `json:"-"`
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentID string `json:"parent_id" binding:"omitempty,uuid"`
+}
+
+// PatchCategory is a JSON Merge Patch of a category. parent_id: null moves
+// the category to the root.
+type PatchCategory struct {
+	Id       string        `json:"-"`
+	Version  int           `json:"-"`
+	Name     Patch[string] `json:"name" swaggertype:"string"`
+	ParentID Patch[string] `json:"parent_id" swaggertype:"string" patch:"nullable"`
+}
+
+const (
+	DeletePolicyRestrict = "restrict"
+	DeletePolicyCascade  = "cascade"
+	DeletePolicyReparent = "reparent"
+)
+
+type DeleteCategory struct {
+	Id      string `json:"id"`
+	Policy  string `json:"policy"`
+	Version int    `json:"-"`
 }
 
 type GetListCategoryRequest struct {
-	Limit  int32
-	Offset int32
+	Page
 }
 
 type GetListCategoryResponse struct {
-	Count      int             `json:"count"`
+	Count      *int            `json:"count,omitempty"`
 	Categories []*CategoryList `json:"categories"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
 type CategoryList struct {
 	Id        string      `json:"id"`
 	Name      string      `json:"name"`
 	ParentID  string      `json:"parent_id"`
+	Version   int         `json:"version"`
 	CreatedAt string      `json:"created_at"`
 	UpdatedAt string      `json:"updated_at"`
 	Childs    []*Category `json:"childs"`
 }
+
+type GetCategoryTreeRequest struct {
+	Id    string
+	Depth int32
+}
+
+type CategoryTree struct {
+	Id        string          `json:"id"`
+	Name      string          `json:"name"`
+	ParentID  string          `json:"parent_id"`
+	Depth     int             `json:"depth"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+	Children  []*CategoryTree `json:"children"`
+}
+
+type CategoryPath struct {
+	Categories []*Category `json:"categories"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	EventOrderCreated        = "order.created"
+	EventOrderUpdated        = "order.updated"
+	EventProductPriceChanged = "product.price_changed"
+	EventCategoryDeleted     = "category.deleted"
+)
+
+// Event is a domain event as it is kept in the outbox and handed to the
+// sinks. Id is stable across retries, so consumers can drop duplicates.
+type Event struct {
+	Id          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   string          `json:"created_at"`
+	Attempts    int             `json:"-"`
+}
+
+// OrderEvent is the payload of order.created and order.updated, the order as
+// it stands after the change.
+type OrderEvent struct {
+	Id          string           `json:"id"`
+	UserID      string           `json:"user_id"`
+	Description string           `json:"description"`
+	Status      string           `json:"status"`
+	Currency    string           `json:"currency"`
+	Total       decimal.Decimal  `json:"total"`
+	Version     int              `json:"version"`
+	Deleted     bool             `json:"deleted"`
+	Items       []OrderEventItem `json:"items"`
+}
+
+type OrderEventItem struct {
+	ProductID string          `json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	Price     decimal.Decimal `json:"price"`
+}
+
+// ProductPriceEvent is the payload of product.price_changed. The event is
+// emitted when the change is made, not when it takes effect: a scheduled
+// change has Scheduled set and EffectiveFrom in the future, and no second
+// event follows once it applies.
+type ProductPriceEvent struct {
+	ProductID     string          `json:"product_id"`
+	Price         decimal.Decimal `json:"price"`
+	Currency      string          `json:"currency"`
+	EffectiveFrom string          `json:"effective_from"`
+	Scheduled     bool            `json:"scheduled"`
+}
+
+// CategoryDeletedEvent is the payload of category.deleted. CategoryIDs lists
+// every category the delete removed, the whole subtree under cascade.
+type CategoryDeletedEvent struct {
+	Id          string   `json:"id"`
+	ParentID    string   `json:"parent_id"`
+	Policy      string   `json:"policy"`
+	CategoryIDs []string `json:"category_ids"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// OrderExportRow is one item of an exported order, with the order it
+// belongs to and the names of its product and category flattened onto it.
+type OrderExportRow struct {
+	OrderID      string
+	UserID       string
+	Description  string
+	Status       string
+	Currency     string
+	Total        decimal.Decimal
+	CreatedAt    string
+	ItemID       string
+	ProductID    string
+	ProductName  string
+	CategoryID   string
+	CategoryName string
+	Quantity     int
+	Price        decimal.Decimal
+	Subtotal     decimal.Decimal
+}
+
+// ProductExportRow is one exported product with the name of its category.
+type ProductExportRow struct {
+	Id            string
+	Name          string
+	Price         decimal.Decimal
+	Currency      string
+	CategoryID    string
+	CategoryName  string
+	StockQuantity int
+	CreatedAt     string
+	UpdatedAt     string
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyKey is a create request remembered under the Idempotency-Key
+// header. Keys are scoped to the user and the route, and StatusCode stays 0
+// while the first request is still being handled.
+type IdempotencyKey struct {
+	UserID      string
+	Route       string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+	TTL         time.Duration
+}
@@ -0,0 +1,68 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// CategoryPathSeparator splits a category path like "Electronics/Phones"
+// into the names from the root down.
+const CategoryPathSeparator = "/"
+
+// ImportCategoryRow is one category of an import. Parent is the id, the
+// name or the path of the parent category, empty for a root category.
+type ImportCategoryRow struct {
+	Line   int    `json:"-"`
+	Name   string `json:"name" binding:"required,max=255"`
+	Parent string `json:"parent" binding:"max=2048"`
+}
+
+// ImportProductRow is one product of an import. Category is the id, the
+// name or the path of its category.
+type ImportProductRow struct {
+	Line          int             `json:"-"`
+	Name          string          `json:"name" binding:"required,max=255"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	Currency      string          `json:"currency" binding:"omitempty,iso4217"`
+	Category      string          `json:"category" binding:"required,max=2048"`
+	StockQuantity int             `json:"stock_quantity" binding:"gte=0"`
+}
+
+// ImportCategories imports the rows in one transaction. A row naming a
+// category that already exists under the same parent is accepted without
+// change, so a catalog can be imported again. CreateMissing creates the
+// parents that do not exist yet.
+type ImportCategories struct {
+	Rows          []*ImportCategoryRow
+	DryRun        bool
+	CreateMissing bool
+}
+
+// ImportProducts imports the rows in one transaction. CreateMissing creates
+// the categories that do not exist yet.
+type ImportProducts struct {
+	Rows          []*ImportProductRow
+	DryRun        bool
+	CreateMissing bool
+}
+
+type ImportError struct {
+	Line   int    `json:"line"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult reports an import. Nothing is written when any row has an
+// error or on a dry run; Created and CreatedCategories then tell what the
+// import would have done.
+type ImportResult struct {
+	DryRun            bool          `json:"dry_run"`
+	Imported          bool          `json:"imported"`
+	Rows              int           `json:"rows"`
+	Accepted          int           `json:"accepted"`
+	Created           int           `json:"created"`
+	CreatedCategories int           `json:"created_categories"`
+	Errors            []ImportError `json:"errors"`
+}
@@ -0,0 +1,55 @@
+package models
+
+const (
+	MovementRestock = "restock"
+	MovementReserve = "reserve"
+	MovementRelease = "release"
+)
+
+// OrderHoldsStock reports whether an order in the status keeps its items
+// reserved: it has not shipped yet, and its items can still change. The
+// stock of a shipped order has left for good.
+func OrderHoldsStock(status string) bool {
+	return status == OrderStatusPending || status == OrderStatusPaid
+}
+
+// OrderReleasesStock reports whether moving an order between the statuses
+// gives its reserved stock back, which is cancelling or refunding it before
+// it shipped.
+func OrderReleasesStock(from, to string) bool {
+	return OrderHoldsStock(from) && (to == OrderStatusCancelled || to == OrderStatusRefunded)
+}
+
+type RestockSwagger struct {
+	Quantity int    `json:"quantity" binding:"required,gt=0"`
+	Comment  string `json:"comment"`
+}
+
+type Restock struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+	Comment   string `json:"comment"`
+}
+
+// InventoryMovement is one entry of the stock ledger. Quantity is positive
+// when stock comes in and negative when an order reserves it.
+type InventoryMovement struct {
+	Id        string `json:"id"`
+	ProductID string `json:"product_id"`
+	OrderID   string `json:"order_id"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+	Comment   string `json:"comment"`
+	CreatedAt string `json:"created_at"`
+}
+
+type GetListMovementRequest struct {
+	ProductID string
+	Limit     int32
+	Offset    int32
+}
+
+type GetListMovementResponse struct {
+	Count     int                 `json:"count"`
+	Movements []InventoryMovement `json:"movements"`
+}
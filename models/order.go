@@ -1,49 +1,88 @@
 package models
 
+import "github.com/shopspring/decimal"
+
 type OrderPrimarKey struct {
 	Id string `json:"id"`
 }
 
+type DeleteOrder struct {
+	Id      string `json:"id"`
+	Version int    `json:"-"`
+}
+
+type CreateOrderItem struct {
+	ProductID string `json:"product_id" binding:"required,uuid"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
+
 type CreateOrder struct {
-	Description string `json:"description"`
-	Product_id  string `json:"product_id"`
+	UserID      string            `json:"-"`
+	Description string            `json:"description"`
+	Items       []CreateOrderItem `json:"items" binding:"required,min=1,dive"`
 }
 
 type Order struct {
 	Id          string `json:"id"`
 	Description string `json:"description"`
-	Product_id  string `json:"product_id"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 	DeletedAt   string `json:"deleted_at"`
 }
 
 type UpdateOrderSwagger struct {
-	Description string `json:"description"`
-	Product_id  string `json:"product_id"`
+	Description string            `json:"description"`
+	Items       []CreateOrderItem `json:"items" binding:"omitempty,dive"`
 }
 
 type UpdateOrder struct {
-	Id          string `json:"id"`
-	Description string `json:"description"`
-	Product_id  string `json:"product_id"`
+	Id          string            `json:"id"`
+	Version     int               `json:"-"`
+	Description string            `json:"description"`
+	Items       []CreateOrderItem `json:"items" binding:"omitempty,dive"`
+}
+
+// PatchOrder is a JSON Merge Patch of an order. description: null clears
+// the description, items replace all the lines like on PUT.
+type PatchOrder struct {
+	Id          string                   `json:"-"`
+	Version     int                      `json:"-"`
+	Description Patch[string]            `json:"description" swaggertype:"string" patch:"nullable"`
+	Items       Patch[[]CreateOrderItem] `json:"items" swaggertype:"array,object"`
 }
 
 type GetListOrderRequest struct {
-	Limit  int32
-	Offset int32
+	Page
+	UserID string
 }
 
 type GetListOrderResponse struct {
-	Count  int         `json:"count"`
-	Orders []OrderList `json:"orders"`
+	Count      *int        `json:"count,omitempty"`
+	Orders     []OrderList `json:"orders"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 type OrderList struct {
-	Id          string      `json:"id"`
-	Description string      `json:"description"`
-	Product     ProductList `json:"product"`
+	Id          string               `json:"id"`
+	UserID      string               `json:"user_id"`
+	Description string               `json:"description"`
+	Status      string               `json:"status"`
+	Items       []OrderItem          `json:"items"`
+	Currency    string               `json:"currency"`
+	Total       decimal.Decimal      `json:"total" swaggertype:"string"`
+	Version     int                  `json:"version"`
+	CreatedAt   string               `json:"created_at"`
+	History     []OrderStatusHistory `json:"history,omitempty"`
 }
+
+type OrderItem struct {
+	Id       string          `json:"id"`
+	Product  ProductList     `json:"product"`
+	Quantity int             `json:"quantity"`
+	Price    decimal.Decimal `json:"price" swaggertype:"string"`
+	Subtotal decimal.Decimal `json:"subtotal" swaggertype:"string"`
+}
+
 type ProductList struct {
 	Id       string          `json:"id"`
 	Name     string          `json:"name"`
@@ -0,0 +1,55 @@
+package models
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// OrderStatusTransitions lists the statuses an order may move to from each
+// status. Cancelled and refunded orders are final.
+var OrderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {OrderStatusRefunded},
+	OrderStatusCancelled: {},
+	OrderStatusRefunded:  {},
+}
+
+func IsOrderStatus(status string) bool {
+	_, ok := OrderStatusTransitions[status]
+	return ok
+}
+
+func CanTransitOrder(from, to string) bool {
+	for _, status := range OrderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+type OrderTransitionSwagger struct {
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type OrderTransition struct {
+	Id      string `json:"id"`
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type OrderStatusHistory struct {
+	Id         string `json:"id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Comment    string `json:"comment"`
+	CreatedAt  string `json:"created_at"`
+}
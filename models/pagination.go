@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Cursor marks the last row of a page. Lists are ordered by (created_at, id),
+// so the next page starts strictly after it.
+type Cursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// Page holds the pagination parameters shared by the list requests. Offset
+// and Cursor are mutually exclusive; Count is only computed when
+// IncludeCount is set.
+type Page struct {
+	Limit        int32
+	Offset       int32
+	Cursor       *Cursor
+	IncludeCount bool
+}
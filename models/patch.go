@@ -0,0 +1,35 @@
+package models
+
+import "encoding/json"
+
+// Patch is a field of a JSON Merge Patch body. A field left out of the body
+// stays unset, a null sets Null and anything else is decoded into Value.
+type Patch[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (p *Patch[T]) UnmarshalJSON(data []byte) error {
+	p.Set = true
+
+	if string(data) == "null" {
+		p.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(data, &p.Value)
+}
+
+// Present and IsNull let the handlers validate patches without knowing T.
+func (p Patch[T]) Present() bool {
+	return p.Set
+}
+
+func (p Patch[T]) IsNull() bool {
+	return p.Null
+}
+
+func (p Patch[T]) Get() interface{} {
+	return p.Value
+}
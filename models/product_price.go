@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ProductPrice is one period of the price schedule. EffectiveTo is empty for
+// the last period, which stays in effect until another change is scheduled.
+type ProductPrice struct {
+	Id            string          `json:"id"`
+	ProductID     string          `json:"product_id"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string"`
+	EffectiveFrom string          `json:"effective_from"`
+	EffectiveTo   string          `json:"effective_to"`
+	CreatedAt     string          `json:"created_at"`
+}
+
+type SchedulePriceSwagger struct {
+	Price         decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	EffectiveFrom time.Time       `json:"effective_from" binding:"required"`
+}
+
+type SchedulePrice struct {
+	ProductID     string          `json:"product_id"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	EffectiveFrom time.Time       `json:"effective_from" binding:"required"`
+}
+
+type ProductPriceHistory struct {
+	Prices []ProductPrice `json:"prices"`
+}
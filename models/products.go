@@ -1,44 +1,91 @@
 package models
 
+import "github.com/shopspring/decimal"
+
+// DefaultCurrency is used for products created without a currency.
+const DefaultCurrency = "USD"
+
 type ProductPrimarKey struct {
 	Id string `json:"id"`
 }
 
+type DeleteProduct struct {
+	Id      string `json:"id"`
+	Version int    `json:"-"`
+}
+
 type CreateProduct struct {
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	CategoryID string  `json:"category_id"`
+	Name          string          `json:"name" binding:"required,max=255"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	Currency      string          `json:"currency" binding:"omitempty,iso4217"`
+	CategoryID    string          `json:"category_id" binding:"required,uuid"`
+	StockQuantity int             `json:"stock_quantity" binding:"gte=0"`
 }
 
 type Product struct {
-	Id         string  `json:"id"`
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	CategoryID string  `json:"category_id"`
-	CreatedAt  string  `json:"created_at"`
-	UpdatedAt  string  `json:"updated_at"`
-	DeletedAt  string  `json:"deleted_at"`
+	Id            string          `json:"id"`
+	Name          string          `json:"name"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string"`
+	Currency      string          `json:"currency"`
+	CategoryID    string          `json:"category_id"`
+	StockQuantity int             `json:"stock_quantity"`
+	Version       int             `json:"version"`
+	CreatedAt     string          `json:"created_at"`
+	UpdatedAt     string          `json:"updated_at"`
+	DeletedAt     string          `json:"deleted_at"`
 }
 
 type UpdateProductSwagger struct {
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	CategoryID string  `json:"category_id"`
+	Name       string          `json:"name" binding:"required,max=255"`
+	Price      decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	Currency   string          `json:"currency" binding:"omitempty,iso4217"`
+	CategoryID string          `json:"category_id" binding:"required,uuid"`
 }
 
 type UpdateProduct struct {
-	Id         string  `json:"id"`
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	CategoryID string  `json:"category_id"`
+	Id         string          `json:"id"`
+	Version    int             `json:"-"`
+	Name       string          `json:"name" binding:"required,max=255"`
+	Price      decimal.Decimal `json:"price" swaggertype:"string" binding:"gte=0"`
+	Currency   string          `json:"currency" binding:"omitempty,iso4217"`
+	CategoryID string          `json:"category_id" binding:"required,uuid"`
+}
+
+// PatchProduct is a JSON Merge Patch of a product. None of its fields can
+// be cleared with null.
+type PatchProduct struct {
+	Id         string                 `json:"-"`
+	Version    int                    `json:"-"`
+	Name       Patch[string]          `json:"name" swaggertype:"string"`
+	Price      Patch[decimal.Decimal] `json:"price" swaggertype:"string"`
+	Currency   Patch[string]          `json:"currency" swaggertype:"string"`
+	CategoryID Patch[string]          `json:"category_id" swaggertype:"string"`
 }
 
+const (
+	ProductSortPrice     = "price"
+	ProductSortName      = "name"
+	ProductSortCreatedAt = "created_at"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
 type GetListProductRequest struct {
-	Limit  int32
-	Offset int32
+	Page
+
+	CategoryID         string
+	IncludeDescendants bool
+	MinPrice           *decimal.Decimal
+	MaxPrice           *decimal.Decimal
+	Search             string
+
+	SortBy    string
+	SortOrder string
 }
 
 type GetListProductResponse struct {
-	Count    int       `json:"count"`
-	Products []Product `json:"products"`
+	Count      *int      `json:"count,omitempty"`
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
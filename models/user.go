@@ -0,0 +1,36 @@
+package models
+
+type UserPrimaryKey struct {
+	Id    string `json:"id"`
+	Login string `json:"login"`
+}
+
+type CreateUser struct {
+	Login    string `json:"login" binding:"required,max=255"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"`
+}
+
+type User struct {
+	Id        string `json:"id"`
+	Login     string `json:"login"`
+	Password  string `json:"-"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type Login struct {
+	Login    string `json:"login" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LoginResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	User         *User  `json:"user"`
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookPrimaryKey struct {
+	Id string `json:"id"`
+	// UserID limits the lookup to the webhooks of one user, empty for all
+	UserID string `json:"-"`
+}
+
+type CreateWebhook struct {
+	UserID    string   `json:"-"`
+	OwnerOnly bool     `json:"-"`
+	URL       string   `json:"url" binding:"required,http_url,max=2048"`
+	Events    []string `json:"events" binding:"required,min=1,dive,oneof=order.created order.updated product.price_changed category.deleted"`
+	Secret    string   `json:"secret" binding:"required,min=16,max=255"`
+}
+
+type UpdateWebhookSwagger struct {
+	URL    string   `json:"url" binding:"required,http_url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=order.created order.updated product.price_changed category.deleted"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Active *bool    `json:"active"`
+}
+
+// UpdateWebhook replaces the webhook. An empty Secret keeps the current one,
+// and Active true turns a disabled webhook back on with a clean slate.
+type UpdateWebhook struct {
+	Id     string   `json:"-"`
+	UserID string   `json:"-"`
+	URL    string   `json:"url" binding:"required,http_url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=order.created order.updated product.price_changed category.deleted"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Active *bool    `json:"active"`
+}
+
+// Webhook is a partner endpoint subscribed to some of the events. The secret
+// is write only. OwnerOnly webhooks get the order events of their owner's
+// orders only; clients can only register those.
+type Webhook struct {
+	Id           string   `json:"id"`
+	UserID       string   `json:"user_id"`
+	URL          string   `json:"url"`
+	Events       []string `json:"events"`
+	OwnerOnly    bool     `json:"owner_only"`
+	Active       bool     `json:"active"`
+	FailureCount int      `json:"failure_count"`
+	DisabledAt   string   `json:"disabled_at"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+type GetListWebhookRequest struct {
+	UserID string
+	Limit  int32
+	Offset int32
+}
+
+type GetListWebhookResponse struct {
+	Count    int        `json:"count"`
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// WebhookEvent is an outbox event on its way to the webhooks. OrderUserID
+// is the owner of the order of an order event, the only user whose
+// OwnerOnly webhooks get it.
+type WebhookEvent struct {
+	Event       *Event
+	IsOrder     bool
+	OrderUserID string
+}
+
+// WebhookDelivery is one event for one webhook, with the outcome of its
+// latest attempt.
+type WebhookDelivery struct {
+	Id          string          `json:"id"`
+	WebhookID   string          `json:"webhook_id"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Attempts    int             `json:"attempts"`
+	StatusCode  int             `json:"status_code"`
+	LastError   string          `json:"last_error"`
+	CreatedAt   string          `json:"created_at"`
+	NextAttempt string          `json:"next_attempt_at"`
+	DeliveredAt string          `json:"delivered_at"`
+
+	// URL and Secret of the webhook, filled in when a delivery is claimed
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type GetListWebhookDeliveryRequest struct {
+	WebhookID string
+	Limit     int32
+	Offset    int32
+}
+
+type GetListWebhookDeliveryResponse struct {
+	Count      int                `json:"count"`
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookAttempt is the outcome of one delivery attempt. A nil RetryAt on a
+// failure gives up on the delivery; the webhook is disabled once
+// DisableAfter attempts in a row have failed.
+type WebhookAttempt struct {
+	DeliveryID   string
+	WebhookID    string
+	StatusCode   int
+	Error        string
+	Delivered    bool
+	RetryAt      *time.Time
+	DisableAfter int
+}
@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursorJSON struct {
+	CreatedAt string `json:"t"`
+	Id        string `json:"id"`
+}
+
+// EncodeCursor turns the position into the opaque next_cursor string.
+func EncodeCursor(cursor models.Cursor) string {
+	data, _ := json.Marshal(cursorJSON{
+		CreatedAt: cursor.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Id:        cursor.Id,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*models.Cursor, error) {
+
+	var c cursorJSON
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, c.CreatedAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if _, err = uuid.Parse(c.Id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.Cursor{CreatedAt: createdAt, Id: c.Id}, nil
+}
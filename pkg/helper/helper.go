@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"strconv"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 func ReplaceQueryParams(namedQuery string, params map[string]interface{}) (string, []interface{}) {
@@ -35,3 +37,10 @@ func NewNullString(s string) sql.NullString {
 		Valid:  true,
 	}
 }
+
+// IsUUID reports whether s is a UUID in the canonical hyphenated form, the
+// only one the ids are written in.
+func IsUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil && len(s) == 36
+}
@@ -0,0 +1,46 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func GenerateJWT(m map[string]interface{}, tokenExpireTime time.Duration, tokenSecretKey string) (string, error) {
+
+	claims := jwt.MapClaims{}
+
+	for key, value := range m {
+		claims[key] = value
+	}
+
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(tokenExpireTime).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(tokenSecretKey))
+}
+
+func ParseClaims(token string, secretKey string) (jwt.MapClaims, error) {
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return []byte(secretKey), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,58 @@
+// Package metrics holds the Prometheus collectors exposed on /metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_query_duration_seconds",
+		Help:    "Latency of the storage repository methods.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"repo", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(HTTPRequests, HTTPDuration, QueryDuration)
+}
+
+// Middleware records every request under its route pattern, not the raw
+// path, so /product/:id stays one series.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveQuery is deferred at the top of a repository method:
+//
+//	defer metrics.ObserveQuery("product", "GetList", time.Now())
+func ObserveQuery(repo, method string, start time.Time) {
+	QueryDuration.WithLabelValues(repo, method).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,184 @@
+// Package outbox delivers the domain events the repositories write into the
+// outbox to the configured sinks.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"crud/config"
+	"crud/models"
+	"crud/storage"
+)
+
+const (
+	// leaseMargin is added to the time the sends of a batch may take, for
+	// the queries recording them
+	leaseMargin = time.Minute
+
+	// maxBackoff caps the doubling wait between attempts
+	maxBackoff = time.Hour
+)
+
+// Lease is how long a claimed batch of batchSize items stays hidden from
+// other workers: long enough for every item to run into the timeout of each
+// of its sends. Items left over by a crash are retried once it runs out.
+func Lease(batchSize, sends int, timeout time.Duration) time.Duration {
+	return time.Duration(batchSize*sends)*timeout + leaseMargin
+}
+
+type Dispatcher struct {
+	repo  storage.OutboxRepoI
+	sinks []Sink
+
+	lease       time.Duration
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewDispatcher(cfg config.Config, repo storage.OutboxRepoI, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		repo:        repo,
+		sinks:       sinks,
+		lease:       Lease(cfg.OutboxBatchSize, len(sinks), sendTimeout),
+		interval:    cfg.OutboxPollInterval,
+		batchSize:   cfg.OutboxBatchSize,
+		maxAttempts: cfg.OutboxMaxAttempts,
+		backoff:     cfg.OutboxRetryBackoff,
+	}
+}
+
+// Sinks builds the sinks cfg enables.
+func Sinks(cfg config.Config) []Sink {
+
+	var sinks []Sink
+
+	if cfg.OutboxStdout {
+		sinks = append(sinks, NewStdout(os.Stdout))
+	}
+
+	if cfg.OutboxWebhookURL != "" {
+		sinks = append(sinks, NewWebhook(cfg.OutboxWebhookURL, nil))
+	}
+
+	return sinks
+}
+
+// Run delivers the due events until ctx is done. A full batch is followed
+// by the next one right away, otherwise it waits for the poll interval.
+func (d *Dispatcher) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("error whiling dispatch outbox: %v\n", err)
+		}
+
+		if n == d.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch claims one batch and delivers it, returning how many events it
+// claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+
+	events, err := d.repo.Claim(ctx, d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		// the events left undelivered on shutdown are retried after the lease
+		if ctx.Err() != nil {
+			return len(events), ctx.Err()
+		}
+
+		err = d.deliver(ctx, event)
+		if err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// deliver hands event to every sink. A failure in any of them retries the
+// event in all, so a sink may see it again, and a retried event can arrive
+// after later ones; the version in the order payloads tells them apart.
+func (d *Dispatcher) deliver(ctx context.Context, event *models.Event) error {
+
+	var failed []string
+
+	for _, sink := range d.sinks {
+		err := send(ctx, sink, event)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return d.repo.MarkDelivered(ctx, event.Id)
+	}
+
+	// a send cut short by shutdown is not the receiver's failure
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	reason := strings.Join(failed, "; ")
+
+	attempts := event.Attempts + 1
+	if attempts >= d.maxAttempts {
+		log.Printf("error whiling deliver event %s %s, giving up after %d attempts: %s\n", event.Type, event.Id, attempts, reason)
+		return d.repo.MarkFailed(ctx, event.Id, reason, nil)
+	}
+
+	retryAt := time.Now().Add(Backoff(d.backoff, attempts))
+
+	log.Printf("error whiling deliver event %s %s, attempt %d: %s\n", event.Type, event.Id, attempts, reason)
+
+	return d.repo.MarkFailed(ctx, event.Id, reason, &retryAt)
+}
+
+// send bounds sink.Send with sendTimeout whatever client the sink uses, so
+// the batch is done within its lease.
+func send(ctx context.Context, sink Sink, event *models.Event) error {
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	return sink.Send(ctx, event)
+}
+
+// Backoff is the wait before the next delivery after attempts failures: base
+// doubled for every failure but the first, capped at an hour.
+func Backoff(base time.Duration, attempts int) time.Duration {
+
+	wait := base
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+
+	return wait
+}
@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"crud/models"
+)
+
+// Sink delivers an event somewhere outside the service. A Sink must be safe
+// to call again with an event it already took, deliveries are at least once.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event *models.Event) error
+}
+
+// Stdout writes every event as one JSON line.
+type Stdout struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdout(w io.Writer) *Stdout {
+	return &Stdout{
+		w: w,
+	}
+}
+
+func (s *Stdout) Name() string {
+	return "stdout"
+}
+
+func (s *Stdout) Send(ctx context.Context, event *models.Event) error {
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(body, '\n'))
+
+	return err
+}
+
+// sendTimeout bounds one Send of any sink, so a slow receiver cannot hold
+// the dispatcher past the lease of its batch.
+const sendTimeout = 10 * time.Second
+
+// Webhook POSTs every event as JSON to a URL. Any status other than 2xx
+// counts as a failed delivery.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, client *http.Client) *Webhook {
+
+	if client == nil {
+		client = &http.Client{Timeout: sendTimeout}
+	}
+
+	return &Webhook{
+		url:    url,
+		client: client,
+	}
+}
+
+func (s *Webhook) Name() string {
+	return "webhook"
+}
+
+func (s *Webhook) Send(ctx context.Context, event *models.Event) error {
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", event.Id)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
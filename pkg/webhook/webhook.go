@@ -0,0 +1,254 @@
+// Package webhook pushes the outbox events to the partner endpoints
+// registered under /webhook.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud/config"
+	"crud/models"
+	"crud/pkg/outbox"
+	"crud/storage"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the webhook secret
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader is the unix time the signature was made at, so
+	// receivers can refuse replays of old deliveries
+	TimestampHeader = "X-Webhook-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// Sign returns the SignatureHeader value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a SignatureHeader value the way a receiver should, in
+// constant time.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Fanout is the outbox sink that queues every event for the webhooks
+// subscribed to it. The deliveries themselves are retried per webhook by
+// the Deliverer, so one failing partner does not hold up the others.
+type Fanout struct {
+	repo storage.WebhookRepoI
+}
+
+func NewFanout(repo storage.WebhookRepoI) *Fanout {
+	return &Fanout{
+		repo: repo,
+	}
+}
+
+func (s *Fanout) Name() string {
+	return "webhooks"
+}
+
+func (s *Fanout) Send(ctx context.Context, event *models.Event) error {
+
+	req := &models.WebhookEvent{
+		Event:   event,
+		IsOrder: strings.HasPrefix(event.Type, "order."),
+	}
+
+	if req.IsOrder {
+		var order models.OrderEvent
+
+		err := json.Unmarshal(event.Payload, &order)
+		if err != nil {
+			return err
+		}
+
+		req.OrderUserID = order.UserID
+	}
+
+	_, err := s.repo.Enqueue(ctx, req)
+
+	return err
+}
+
+type Deliverer struct {
+	repo   storage.WebhookRepoI
+	client *http.Client
+
+	// timeout bounds every send, whatever client does, and lease is sized
+	// for a batch of sends that all run into it
+	timeout      time.Duration
+	lease        time.Duration
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoff      time.Duration
+	disableAfter int
+}
+
+// NewDeliverer sends the deliveries with client, or with NewClient limited
+// to cfg.WebhookTimeout when it is nil.
+func NewDeliverer(cfg config.Config, repo storage.WebhookRepoI, client *http.Client) *Deliverer {
+
+	if client == nil {
+		client = NewClient(cfg.WebhookTimeout)
+	}
+
+	return &Deliverer{
+		repo:         repo,
+		client:       client,
+		timeout:      cfg.WebhookTimeout,
+		lease:        outbox.Lease(cfg.OutboxBatchSize, 1, cfg.WebhookTimeout),
+		interval:     cfg.OutboxPollInterval,
+		batchSize:    cfg.OutboxBatchSize,
+		maxAttempts:  cfg.WebhookMaxAttempts,
+		backoff:      cfg.WebhookRetryBackoff,
+		disableAfter: cfg.WebhookDisableAfter,
+	}
+}
+
+// Run sends the due deliveries until ctx is done. A full batch is followed
+// by the next one right away, otherwise it waits for the poll interval.
+func (d *Deliverer) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.Deliver(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("error whiling deliver webhooks: %v\n", err)
+		}
+
+		if n == d.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver claims one batch of due deliveries and sends it, returning how
+// many it claimed.
+func (d *Deliverer) Deliver(ctx context.Context) (int, error) {
+
+	deliveries, err := d.repo.ClaimDeliveries(ctx, d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		// the deliveries left unsent on shutdown are retried after the lease
+		if ctx.Err() != nil {
+			return len(deliveries), ctx.Err()
+		}
+
+		err = d.attempt(ctx, delivery)
+		if err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *Deliverer) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+
+	statusCode, err := d.send(ctx, delivery)
+
+	// a send cut short by shutdown is not the receiver's failure
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	attempt := &models.WebhookAttempt{
+		DeliveryID:   delivery.Id,
+		WebhookID:    delivery.WebhookID,
+		StatusCode:   statusCode,
+		Delivered:    err == nil,
+		DisableAfter: d.disableAfter,
+	}
+
+	if err != nil {
+		attempt.Error = err.Error()
+
+		attempts := delivery.Attempts + 1
+		if attempts < d.maxAttempts {
+			retryAt := time.Now().Add(outbox.Backoff(d.backoff, attempts))
+			attempt.RetryAt = &retryAt
+		}
+
+		log.Printf("error whiling deliver webhook %s event %s, attempt %d: %v\n", delivery.WebhookID, delivery.EventType, attempts, err)
+	}
+
+	disabled, err := d.repo.RecordAttempt(ctx, attempt)
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		log.Printf("webhook %s disabled after %d failed deliveries in a row\n", delivery.WebhookID, d.disableAfter)
+	}
+
+	return nil
+}
+
+// send POSTs the event and returns the status code of the answer. Any
+// status other than 2xx is an error.
+func (d *Deliverer) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(DeliveryHeader, delivery.Id)
+	req.Header.Set(EventHeader, delivery.EventType)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,326 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"crud/config"
+	"crud/models"
+	"crud/pkg/webhook"
+	"crud/storage"
+	"crud/storage/memory"
+)
+
+const secret = "0123456789abcdef"
+
+func TestSignVerify(t *testing.T) {
+
+	var (
+		body      = []byte(`{"id":"1"}`)
+		timestamp = int64(1700000000)
+		signature = webhook.Sign(secret, timestamp, body)
+	)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"same request", secret, timestamp, body, signature, true},
+		{"other body", secret, timestamp, []byte(`{"id":"2"}`), signature, false},
+		{"other timestamp", secret, timestamp + 1, body, signature, false},
+		{"other secret", "fedcba9876543210", timestamp, body, signature, false},
+		{"no prefix", secret, timestamp, body, signature[len("sha256="):], false},
+		{"empty", secret, timestamp, body, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhook.Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// receiver answers the deliveries with the next status of statuses, the
+// last one once they run out, and fails the test on a bad signature.
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	calls    int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	body, _ := io.ReadAll(req.Body)
+
+	timestamp, err := strconv.ParseInt(req.Header.Get(webhook.TimestampHeader), 10, 64)
+	if err != nil || !webhook.Verify(secret, timestamp, body, req.Header.Get(webhook.SignatureHeader)) {
+		r.t.Errorf("delivery %s has a bad signature", req.Header.Get(webhook.DeliveryHeader))
+	}
+
+	if got := req.Header.Get(webhook.EventHeader); got != models.EventCategoryDeleted {
+		r.t.Errorf("event header = %q, want %q", got, models.EventCategoryDeleted)
+	}
+
+	r.mu.Lock()
+	status := r.statuses[len(r.statuses)-1]
+	if r.calls < len(r.statuses) {
+		status = r.statuses[r.calls]
+	}
+	r.calls++
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+// setup registers a webhook for an httptest server of handler and queues
+// one event for it.
+func setup(t *testing.T, handler http.Handler, cfg config.Config) (*webhook.Deliverer, storage.WebhookRepoI, string) {
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	var (
+		ctx  = context.Background()
+		repo = memory.NewMemory().Webhook()
+	)
+
+	id, err := repo.Create(ctx, &models.CreateWebhook{
+		URL:    server.URL,
+		Events: []string{models.EventCategoryDeleted},
+		Secret: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = webhook.NewFanout(repo).Send(ctx, &models.Event{
+		Id:          "4b1c1d5e-2f0a-4a57-9d55-6f1e8c4a0b01",
+		Type:        models.EventCategoryDeleted,
+		AggregateID: "4b1c1d5e-2f0a-4a57-9d55-6f1e8c4a0b02",
+		Payload:     []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test server listens on loopback, which the default client refuses
+	return webhook.NewDeliverer(cfg, repo, server.Client()), repo, id
+}
+
+func testConfig() config.Config {
+	return config.Config{
+		OutboxPollInterval:  time.Millisecond,
+		OutboxBatchSize:     10,
+		WebhookTimeout:      5 * time.Second,
+		WebhookMaxAttempts:  10,
+		WebhookRetryBackoff: 0,
+		WebhookDisableAfter: 3,
+	}
+}
+
+func delivery(t *testing.T, repo storage.WebhookRepoI, webhookID string) *models.WebhookDelivery {
+
+	resp, err := repo.GetDeliveries(context.Background(), &models.GetListWebhookDeliveryRequest{WebhookID: webhookID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(resp.Deliveries))
+	}
+
+	return resp.Deliveries[0]
+}
+
+func deliver(t *testing.T, d *webhook.Deliverer, want int) {
+
+	n, err := d.Deliver(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != want {
+		t.Fatalf("Deliver() claimed %d deliveries, want %d", n, want)
+	}
+}
+
+func TestDeliverRetriesThenSucceeds(t *testing.T) {
+
+	recv := &receiver{t: t, statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent}}
+	d, repo, id := setup(t, recv, testConfig())
+
+	want := []struct {
+		attempts   int
+		statusCode int
+		delivered  bool
+	}{
+		{1, http.StatusInternalServerError, false},
+		{2, http.StatusBadGateway, false},
+		{3, http.StatusNoContent, true},
+	}
+
+	for _, w := range want {
+		deliver(t, d, 1)
+
+		got := delivery(t, repo, id)
+
+		if got.Attempts != w.attempts || got.StatusCode != w.statusCode || (got.DeliveredAt != "") != w.delivered {
+			t.Fatalf("delivery = attempts %d, status %d, delivered %q; want attempts %d, status %d, delivered %v",
+				got.Attempts, got.StatusCode, got.DeliveredAt, w.attempts, w.statusCode, w.delivered)
+		}
+
+		if !w.delivered && (got.LastError == "" || got.NextAttempt == "") {
+			t.Fatalf("failed delivery has error %q and next attempt %q, want both", got.LastError, got.NextAttempt)
+		}
+
+		if w.delivered && got.NextAttempt != "" {
+			t.Fatalf("delivered delivery is still scheduled at %s", got.NextAttempt)
+		}
+	}
+
+	// nothing is left to send
+	deliver(t, d, 0)
+
+	hook, err := repo.GetByPKey(context.Background(), &models.WebhookPrimaryKey{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hook.Active || hook.FailureCount != 0 {
+		t.Errorf("webhook active %v with %d failures, want active with 0 after a success", hook.Active, hook.FailureCount)
+	}
+}
+
+func TestDeliverDisablesFailingWebhook(t *testing.T) {
+
+	recv := &receiver{t: t, statuses: []int{http.StatusServiceUnavailable}}
+	d, repo, id := setup(t, recv, testConfig())
+
+	for i := 0; i < 3; i++ {
+		deliver(t, d, 1)
+	}
+
+	got := delivery(t, repo, id)
+	if got.Attempts != 3 || got.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("delivery has %d attempts with status %d, want 3 with %d", got.Attempts, got.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	hook, err := repo.GetByPKey(context.Background(), &models.WebhookPrimaryKey{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hook.Active || hook.DisabledAt == "" || hook.FailureCount != 3 {
+		t.Errorf("webhook active %v, disabled at %q with %d failures; want disabled with 3", hook.Active, hook.DisabledAt, hook.FailureCount)
+	}
+
+	// a disabled webhook gets nothing until it is turned back on
+	deliver(t, d, 0)
+
+	if recv.calls != 3 {
+		t.Errorf("receiver got %d requests, want 3", recv.calls)
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+
+	cfg := testConfig()
+	cfg.WebhookMaxAttempts = 2
+	cfg.WebhookDisableAfter = 20
+
+	recv := &receiver{t: t, statuses: []int{http.StatusInternalServerError}}
+	d, repo, id := setup(t, recv, cfg)
+
+	deliver(t, d, 1)
+	deliver(t, d, 1)
+	deliver(t, d, 0)
+
+	got := delivery(t, repo, id)
+	if got.Attempts != 2 || got.NextAttempt != "" || got.DeliveredAt != "" {
+		t.Errorf("delivery has %d attempts, next attempt %q, delivered %q; want 2 and given up", got.Attempts, got.NextAttempt, got.DeliveredAt)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect was followed")
+	}))
+	defer target.Close()
+
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	})
+
+	_, repo, id := setup(t, redirect, testConfig())
+
+	// the redirect policy of NewClient, over a transport that may dial loopback
+	client := webhook.NewClient(time.Second)
+	client.Transport = http.DefaultTransport
+
+	d := webhook.NewDeliverer(testConfig(), repo, client)
+
+	deliver(t, d, 1)
+
+	if got := delivery(t, repo, id); got.StatusCode != http.StatusFound || got.DeliveredAt != "" {
+		t.Errorf("delivery has status %d, delivered %q; want a failed %d", got.StatusCode, got.DeliveredAt, http.StatusFound)
+	}
+}
+
+func TestNewClientRefusesInternalAddresses(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := webhook.NewClient(time.Second).Get(server.URL)
+	if !errors.Is(err, webhook.ErrForbiddenAddress) {
+		t.Errorf("Get(%s) error = %v, want %v", server.URL, err, webhook.ErrForbiddenAddress)
+	}
+}
+
+func TestPublicHost(t *testing.T) {
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := webhook.PublicHost(tt.host); got != tt.want {
+			t.Errorf("PublicHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package cache is a read-through cache in front of a storage.StorageI. It
+// keeps products, categories and the category tree, and drops the affected
+// keys on every write that goes through it.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"crud/config"
+	"crud/storage"
+)
+
+// ErrMiss is returned by Backend.Get for a key that is not cached.
+var ErrMiss = errors.New("cache miss")
+
+// Backend is the key/value store the cache keeps its entries in.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	// DelPrefix drops every key starting with prefix
+	DelPrefix(ctx context.Context, prefix string) error
+	Close() error
+}
+
+const (
+	prefix         = "crud:"
+	productPrefix  = prefix + "product:"
+	categoryPrefix = prefix + "category:"
+	treePrefix     = prefix + "category_tree:"
+)
+
+type Store struct {
+	storage.StorageI
+
+	backend Backend
+	ttl     time.Duration
+	treeTTL time.Duration
+
+	category *CategoryRepo
+	product  *ProductRepo
+	order    *OrderRepo
+}
+
+// NewCache wraps store, caching its lookups in backend for the TTLs of cfg.
+func NewCache(cfg config.Config, store storage.StorageI, backend Backend) storage.StorageI {
+	s := &Store{
+		StorageI: store,
+		backend:  backend,
+		ttl:      cfg.CacheTTL,
+		treeTTL:  cfg.CacheTreeTTL,
+	}
+
+	s.category = NewCategoryRepo(s, store.Category())
+	s.product = NewProductRepo(s, store.Product())
+	s.order = NewOrderRepo(s, store.Order())
+
+	return s
+}
+
+// NewBackend builds the backend selected by cfg.CacheType. A redis server
+// that does not answer falls back to the in-process LRU.
+func NewBackend(ctx context.Context, cfg config.Config) Backend {
+
+	if cfg.CacheType == config.CacheRedis {
+		backend, err := NewRedis(ctx, cfg)
+		if err == nil {
+			return backend
+		}
+
+		log.Printf("error whiling connect redis, falling back to lru: %v\n", err)
+	}
+
+	return NewLRU(cfg.CacheSize)
+}
+
+func (s *Store) CloseDB() {
+
+	err := s.backend.Close()
+	if err != nil {
+		log.Printf("error whiling close cache: %v\n", err)
+	}
+
+	s.StorageI.CloseDB()
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return s.category
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return s.product
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+	return s.order
+}
+
+// get decodes the cached value of key into dest. Backend errors count as a
+// miss, so a cache outage only costs the storage round trip.
+func (s *Store) get(ctx context.Context, key string, dest interface{}) bool {
+
+	value, err := s.backend.Get(ctx, key)
+	if err != nil {
+		if err != ErrMiss {
+			log.Printf("error whiling cache get %s: %v\n", key, err)
+		}
+
+		return false
+	}
+
+	err = json.Unmarshal(value, dest)
+	if err != nil {
+		log.Printf("error whiling cache decode %s: %v\n", key, err)
+		return false
+	}
+
+	return true
+}
+
+func (s *Store) set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("error whiling cache encode %s: %v\n", key, err)
+		return
+	}
+
+	err = s.backend.Set(ctx, key, body, ttl)
+	if err != nil {
+		log.Printf("error whiling cache set %s: %v\n", key, err)
+	}
+}
+
+// del drops keys after a write. A failure leaves the stale entries until
+// their TTL runs out, which is all a write can do at that point.
+func (s *Store) del(ctx context.Context, keys ...string) {
+
+	err := s.backend.Del(ctx, keys...)
+	if err != nil {
+		log.Printf("error whiling cache del %v: %v\n", keys, err)
+	}
+}
+
+func (s *Store) delPrefix(ctx context.Context, prefix string) {
+
+	err := s.backend.DelPrefix(ctx, prefix)
+	if err != nil {
+		log.Printf("error whiling cache del %s*: %v\n", prefix, err)
+	}
+}
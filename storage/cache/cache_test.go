@@ -0,0 +1,329 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"crud/config"
+	"crud/models"
+	"crud/storage"
+	"crud/storage/memory"
+)
+
+// countingStore counts the lookups that get past the cache to the storage.
+type countingStore struct {
+	storage.StorageI
+
+	category *countingCategories
+	product  *countingProducts
+}
+
+type countingCategories struct {
+	storage.CategoryRepoI
+
+	byPKey int
+	tree   int
+}
+
+type countingProducts struct {
+	storage.ProductRepoI
+
+	byPKey int
+}
+
+func (s *countingStore) Category() storage.CategoryRepoI {
+	return s.category
+}
+
+func (s *countingStore) Product() storage.ProductRepoI {
+	return s.product
+}
+
+func (f *countingCategories) GetByPKey(ctx context.Context, req *models.CategoryPrimaryKey) (*models.CategoryList, error) {
+	f.byPKey++
+	return f.CategoryRepoI.GetByPKey(ctx, req)
+}
+
+func (f *countingCategories) GetTree(ctx context.Context, req *models.GetCategoryTreeRequest) ([]*models.CategoryTree, error) {
+	f.tree++
+	return f.CategoryRepoI.GetTree(ctx, req)
+}
+
+func (f *countingProducts) GetByPKey(ctx context.Context, req *models.ProductPrimarKey) (*models.Product, error) {
+	f.byPKey++
+	return f.ProductRepoI.GetByPKey(ctx, req)
+}
+
+const cacheTTL = time.Hour
+
+func newTestCache(t *testing.T) (storage.StorageI, *countingStore, *LRU) {
+
+	store := memory.NewMemory()
+
+	counting := &countingStore{
+		StorageI: store,
+		category: &countingCategories{CategoryRepoI: store.Category()},
+		product:  &countingProducts{ProductRepoI: store.Product()},
+	}
+
+	backend := NewLRU(100)
+
+	cfg := config.Config{
+		CacheTTL:     cacheTTL,
+		CacheTreeTTL: cacheTTL,
+	}
+
+	return NewCache(cfg, counting, backend), counting, backend
+}
+
+// cached reports whether key is in the backend.
+func cached(t *testing.T, backend *LRU, key string) bool {
+
+	_, err := backend.Get(context.Background(), key)
+	if err != nil && err != ErrMiss {
+		t.Fatal(err)
+	}
+
+	return err == nil
+}
+
+func createCategory(t *testing.T, s storage.StorageI, name, parentID string) string {
+
+	id, err := s.Category().Create(context.Background(), &models.CreateCategory{Name: name, ParentID: parentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func createProduct(t *testing.T, s storage.StorageI, categoryID string) string {
+
+	id, err := s.Product().Create(context.Background(), &models.CreateProduct{
+		Name:          "phone",
+		Price:         decimal.NewFromInt(100),
+		Currency:      models.DefaultCurrency,
+		CategoryID:    categoryID,
+		StockQuantity: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func TestCategoryReadThrough(t *testing.T) {
+
+	var (
+		ctx            = context.Background()
+		s, counting, _ = newTestCache(t)
+		parentID       = createCategory(t, s, "electronics", "")
+		tree           = &models.GetCategoryTreeRequest{Depth: 2}
+	)
+
+	for i := 0; i < 3; i++ {
+		resp, err := s.Category().GetByPKey(ctx, &models.CategoryPrimaryKey{Id: parentID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Name != "electronics" {
+			t.Fatalf("GetByPKey() name = %q, want electronics", resp.Name)
+		}
+
+		_, err = s.Category().GetTree(ctx, tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the first read reaches the storage
+	if counting.category.byPKey != 1 || counting.category.tree != 1 {
+		t.Errorf("storage got %d lookups and %d trees for three reads, want 1 and 1", counting.category.byPKey, counting.category.tree)
+	}
+}
+
+func TestCategoryUpdateInvalidates(t *testing.T) {
+
+	var (
+		ctx             = context.Background()
+		s, _, backend   = newTestCache(t)
+		oldParentID     = createCategory(t, s, "electronics", "")
+		newParentID     = createCategory(t, s, "gadgets", "")
+		id              = createCategory(t, s, "phones", oldParentID)
+		tree            = &models.GetCategoryTreeRequest{Depth: 3}
+		categoryLookups = []string{id, oldParentID, newParentID}
+	)
+
+	warm := func() {
+		for _, id := range categoryLookups {
+			_, err := s.Category().GetByPKey(ctx, &models.CategoryPrimaryKey{Id: id})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := s.Category().GetTree(ctx, tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	warm()
+
+	_, err := s.Category().Update(ctx, &models.UpdateCategory{Id: id, Name: "smartphones", ParentID: newParentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the category, the parent it left, the parent it joined and the tree
+	for _, key := range []string{categoryKey(id), categoryKey(oldParentID), categoryKey(newParentID), treeKey(tree)} {
+		if cached(t, backend, key) {
+			t.Errorf("%s is still cached after Update", key)
+		}
+	}
+
+	resp, err := s.Category().GetByPKey(ctx, &models.CategoryPrimaryKey{Id: newParentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Childs) != 1 || resp.Childs[0].Name != "smartphones" {
+		t.Errorf("new parent has childs %+v, want the updated category", resp.Childs)
+	}
+
+	warm()
+
+	err = s.Category().Delete(ctx, &models.DeleteCategory{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{categoryKey(id), categoryKey(newParentID), treeKey(tree)} {
+		if cached(t, backend, key) {
+			t.Errorf("%s is still cached after Delete", key)
+		}
+	}
+
+	_, err = s.Category().GetByPKey(ctx, &models.CategoryPrimaryKey{Id: id})
+	if storage.KindOf(err) != storage.KindNotFound {
+		t.Errorf("GetByPKey() of a deleted category error = %v, want not found", err)
+	}
+}
+
+func TestProductWritesInvalidate(t *testing.T) {
+
+	var (
+		ctx                  = context.Background()
+		s, counting, backend = newTestCache(t)
+		categoryID           = createCategory(t, s, "electronics", "")
+		id                   = createProduct(t, s, categoryID)
+		key                  = productKey(id)
+	)
+
+	get := func() *models.Product {
+		resp, err := s.Product().GetByPKey(ctx, &models.ProductPrimarKey{Id: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return resp
+	}
+
+	get()
+	get()
+
+	if counting.product.byPKey != 1 {
+		t.Errorf("storage got %d lookups for two reads, want 1", counting.product.byPKey)
+	}
+
+	_, err := s.Product().Update(ctx, &models.UpdateProduct{
+		Id:         id,
+		Name:       "smartphone",
+		Price:      decimal.NewFromInt(100),
+		Currency:   models.DefaultCurrency,
+		CategoryID: categoryID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached(t, backend, key) {
+		t.Errorf("%s is still cached after Update", key)
+	}
+
+	if got := get(); got.Name != "smartphone" {
+		t.Errorf("GetByPKey() after Update name = %q, want smartphone", got.Name)
+	}
+
+	err = s.Product().Delete(ctx, &models.DeleteProduct{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached(t, backend, key) {
+		t.Errorf("%s is still cached after Delete", key)
+	}
+
+	_, err = s.Product().GetByPKey(ctx, &models.ProductPrimarKey{Id: id})
+	if storage.KindOf(err) != storage.KindNotFound {
+		t.Errorf("GetByPKey() of a deleted product error = %v, want not found", err)
+	}
+}
+
+func TestProductTTLEndsAtNextScheduledPrice(t *testing.T) {
+
+	var (
+		ctx           = context.Background()
+		s, _, backend = newTestCache(t)
+		categoryID    = createCategory(t, s, "electronics", "")
+		plain         = createProduct(t, s, categoryID)
+		scheduled     = createProduct(t, s, categoryID)
+		until         = 10 * time.Minute
+	)
+
+	err := s.Product().SchedulePrice(ctx, &models.SchedulePrice{
+		ProductID:     scheduled,
+		Price:         decimal.NewFromInt(80),
+		EffectiveFrom: time.Now().Add(until),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		ttl  time.Duration
+	}{
+		{"no scheduled price", plain, cacheTTL},
+		{"scheduled price", scheduled, until},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			start := time.Now()
+
+			_, err := s.Product().GetByPKey(ctx, &models.ProductPrimarKey{Id: tt.id})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			el, ok := backend.entries[productKey(tt.id)]
+			if !ok {
+				t.Fatal("product is not cached")
+			}
+
+			// the entry expires ttl after the lookup, give or take its duration
+			expiresAt := el.Value.(*lruEntry).expiresAt
+			if expiresAt.Before(start.Add(tt.ttl-time.Second)) || expiresAt.After(time.Now().Add(tt.ttl)) {
+				t.Errorf("entry expires in %s, want %s", expiresAt.Sub(start).Round(time.Second), tt.ttl)
+			}
+		})
+	}
+}
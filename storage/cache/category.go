@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type CategoryRepo struct {
+	storage.CategoryRepoI
+
+	s *Store
+}
+
+func NewCategoryRepo(s *Store, repo storage.CategoryRepoI) *CategoryRepo {
+	return &CategoryRepo{
+		CategoryRepoI: repo,
+		s:             s,
+	}
+}
+
+func categoryKey(id string) string {
+	return categoryPrefix + id
+}
+
+func treeKey(req *models.GetCategoryTreeRequest) string {
+	return fmt.Sprintf("%s%s:%d", treePrefix, req.Id, req.Depth)
+}
+
+func (f *CategoryRepo) GetByPKey(ctx context.Context, req *models.CategoryPrimaryKey) (*models.CategoryList, error) {
+
+	var (
+		key  = categoryKey(req.Id)
+		resp = &models.CategoryList{}
+	)
+
+	if f.s.get(ctx, key, resp) {
+		return resp, nil
+	}
+
+	resp, err := f.CategoryRepoI.GetByPKey(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	f.s.set(ctx, key, resp, f.s.ttl)
+
+	return resp, nil
+}
+
+func (f *CategoryRepo) GetTree(ctx context.Context, req *models.GetCategoryTreeRequest) ([]*models.CategoryTree, error) {
+
+	var (
+		key  = treeKey(req)
+		resp []*models.CategoryTree
+	)
+
+	if f.s.get(ctx, key, &resp) {
+		return resp, nil
+	}
+
+	resp, err := f.CategoryRepoI.GetTree(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	f.s.set(ctx, key, resp, f.s.treeTTL)
+
+	return resp, nil
+}
+
+func (f *CategoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
+
+	id, err := f.CategoryRepoI.Create(ctx, req)
+	if err != nil {
+		return id, err
+	}
+
+	// the parent lists the new category among its childs
+	f.invalidate(ctx, req.ParentID)
+
+	return id, nil
+}
+
+func (f *CategoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+
+	parentID := f.parentOf(ctx, req.Id)
+
+	rowsAffected, err := f.CategoryRepoI.Update(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.invalidate(ctx, req.Id, parentID, req.ParentID)
+
+	return rowsAffected, nil
+}
+
+func (f *CategoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+
+	parentID := f.parentOf(ctx, req.Id)
+
+	rowsAffected, err := f.CategoryRepoI.Patch(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.invalidate(ctx, req.Id, parentID, req.ParentID.Value)
+
+	return rowsAffected, nil
+}
+
+func (f *CategoryRepo) Delete(ctx context.Context, req *models.DeleteCategory) error {
+
+	parentID := f.parentOf(ctx, req.Id)
+
+	err := f.CategoryRepoI.Delete(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	switch req.Policy {
+	case models.DeletePolicyCascade, models.DeletePolicyReparent:
+		// the whole subtree and its products may have moved or gone
+		f.s.delPrefix(ctx, categoryPrefix)
+		f.s.delPrefix(ctx, productPrefix)
+		f.s.delPrefix(ctx, treePrefix)
+	default:
+		f.invalidate(ctx, req.Id, parentID)
+	}
+
+	return nil
+}
+
+func (f *CategoryRepo) Import(ctx context.Context, req *models.ImportCategories) (*models.ImportResult, error) {
+
+	resp, err := f.CategoryRepoI.Import(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.Imported {
+		// the new categories show up among the childs of their parents
+		f.s.delPrefix(ctx, categoryPrefix)
+		f.s.delPrefix(ctx, treePrefix)
+	}
+
+	return resp, nil
+}
+
+// parentOf reads the current parent of id from the storage, so a write can
+// drop it from the cache along with the new one.
+func (f *CategoryRepo) parentOf(ctx context.Context, id string) string {
+
+	c, err := f.CategoryRepoI.GetByPKey(ctx, &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		return ""
+	}
+
+	return c.ParentID
+}
+
+// invalidate drops the given categories and every cached tree, since any
+// change shows up in the trees above it.
+func (f *CategoryRepo) invalidate(ctx context.Context, ids ...string) {
+
+	var keys []string
+	for _, id := range ids {
+		if id != "" {
+			keys = append(keys, categoryKey(id))
+		}
+	}
+
+	if len(keys) > 0 {
+		f.s.del(ctx, keys...)
+	}
+
+	f.s.delPrefix(ctx, treePrefix)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is the in-process Backend. It keeps up to size entries and evicts the
+// least recently used one first.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.remove(el)
+		return nil, ErrMiss
+	}
+
+	l.order.MoveToFront(el)
+
+	return entry.value, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &lruEntry{
+		key:       key,
+		value:     value,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	if el, ok := l.entries[key]; ok {
+		el.Value = entry
+		l.order.MoveToFront(el)
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(entry)
+
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+
+	return nil
+}
+
+func (l *LRU) Del(ctx context.Context, keys ...string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := l.entries[key]; ok {
+			l.remove(el)
+		}
+	}
+
+	return nil
+}
+
+func (l *LRU) DelPrefix(ctx context.Context, prefix string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, el := range l.entries {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(el)
+		}
+	}
+
+	return nil
+}
+
+func (l *LRU) Close() error {
+	return nil
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+
+	"crud/models"
+	"crud/storage"
+)
+
+// OrderRepo caches nothing itself. Orders reserve and release stock, so it
+// drops the cached products whose stock_quantity an order write moves.
+type OrderRepo struct {
+	storage.OrderRepoI
+
+	s *Store
+}
+
+func NewOrderRepo(s *Store, repo storage.OrderRepoI) *OrderRepo {
+	return &OrderRepo{
+		OrderRepoI: repo,
+		s:          s,
+	}
+}
+
+func (f *OrderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
+
+	id, err := f.OrderRepoI.Create(ctx, req)
+	if err != nil {
+		return id, err
+	}
+
+	f.invalidate(ctx, nil, req.Items)
+
+	return id, nil
+}
+
+func (f *OrderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+
+	ids := f.productsOf(ctx, req.Id)
+
+	rowsAffected, err := f.OrderRepoI.Update(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.invalidate(ctx, ids, req.Items)
+
+	return rowsAffected, nil
+}
+
+func (f *OrderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+
+	ids := f.productsOf(ctx, req.Id)
+
+	rowsAffected, err := f.OrderRepoI.Patch(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.invalidate(ctx, ids, req.Items.Value)
+
+	return rowsAffected, nil
+}
+
+func (f *OrderRepo) Delete(ctx context.Context, req *models.DeleteOrder) error {
+
+	ids := f.productsOf(ctx, req.Id)
+
+	err := f.OrderRepoI.Delete(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	f.invalidate(ctx, ids, nil)
+
+	return nil
+}
+
+func (f *OrderRepo) Transition(ctx context.Context, req *models.OrderTransition) error {
+
+	ids := f.productsOf(ctx, req.Id)
+
+	err := f.OrderRepoI.Transition(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	f.invalidate(ctx, ids, nil)
+
+	return nil
+}
+
+// productsOf lists the products an order currently holds stock of.
+func (f *OrderRepo) productsOf(ctx context.Context, id string) []string {
+
+	o, err := f.OrderRepoI.GetByPKey(ctx, &models.OrderPrimarKey{Id: id})
+	if err != nil {
+		return nil
+	}
+
+	var ids []string
+	for _, item := range o.Items {
+		ids = append(ids, item.Product.Id)
+	}
+
+	return ids
+}
+
+func (f *OrderRepo) invalidate(ctx context.Context, ids []string, items []models.CreateOrderItem) {
+
+	var keys []string
+	for _, id := range ids {
+		keys = append(keys, productKey(id))
+	}
+
+	for _, item := range items {
+		keys = append(keys, productKey(item.ProductID))
+	}
+
+	if len(keys) > 0 {
+		f.s.del(ctx, keys...)
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type ProductRepo struct {
+	storage.ProductRepoI
+
+	s *Store
+}
+
+func NewProductRepo(s *Store, repo storage.ProductRepoI) *ProductRepo {
+	return &ProductRepo{
+		ProductRepoI: repo,
+		s:            s,
+	}
+}
+
+func productKey(id string) string {
+	return productPrefix + id
+}
+
+func (f *ProductRepo) GetByPKey(ctx context.Context, req *models.ProductPrimarKey) (*models.Product, error) {
+
+	var (
+		key  = productKey(req.Id)
+		resp = &models.Product{}
+	)
+
+	if f.s.get(ctx, key, resp) {
+		return resp, nil
+	}
+
+	resp, err := f.ProductRepoI.GetByPKey(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if ttl := f.ttl(ctx, req.Id); ttl > 0 {
+		f.s.set(ctx, key, resp, ttl)
+	}
+
+	return resp, nil
+}
+
+// ttl cuts the cache TTL short at the next scheduled price change, which
+// takes effect without a write to drop the entry.
+func (f *ProductRepo) ttl(ctx context.Context, id string) time.Duration {
+
+	ttl := f.s.ttl
+
+	prices, err := f.ProductRepoI.GetPrices(ctx, &models.ProductPrimarKey{Id: id})
+	if err != nil {
+		return ttl
+	}
+
+	t := time.Now()
+
+	for _, price := range prices.Prices {
+		from, ok := parseTime(price.EffectiveFrom)
+		if !ok || !from.After(t) {
+			continue
+		}
+
+		if until := from.Sub(t); until < ttl {
+			ttl = until
+		}
+	}
+
+	return ttl
+}
+
+func (f *ProductRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+
+	rowsAffected, err := f.ProductRepoI.Update(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.s.del(ctx, productKey(req.Id))
+
+	return rowsAffected, nil
+}
+
+func (f *ProductRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+
+	rowsAffected, err := f.ProductRepoI.Patch(ctx, req)
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	f.s.del(ctx, productKey(req.Id))
+
+	return rowsAffected, nil
+}
+
+func (f *ProductRepo) Delete(ctx context.Context, req *models.DeleteProduct) error {
+
+	err := f.ProductRepoI.Delete(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	f.s.del(ctx, productKey(req.Id))
+
+	return nil
+}
+
+func (f *ProductRepo) Restock(ctx context.Context, req *models.Restock) error {
+
+	err := f.ProductRepoI.Restock(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	f.s.del(ctx, productKey(req.ProductID))
+
+	return nil
+}
+
+func (f *ProductRepo) SchedulePrice(ctx context.Context, req *models.SchedulePrice) error {
+
+	err := f.ProductRepoI.SchedulePrice(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	f.s.del(ctx, productKey(req.ProductID))
+
+	return nil
+}
+
+// timeLayouts are the formats the backends print timestamps in: RFC 3339 from
+// memory, the timestamptz text output from postgres.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+}
+
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (f *ProductRepo) Import(ctx context.Context, req *models.ImportProducts) (*models.ImportResult, error) {
+
+	resp, err := f.ProductRepoI.Import(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	// the products are new, only the categories created for them can be cached
+	if resp.Imported && resp.CreatedCategories > 0 {
+		// the new categories show up among the childs of their parents
+		f.s.delPrefix(ctx, categoryPrefix)
+		f.s.delPrefix(ctx, treePrefix)
+	}
+
+	return resp, nil
+}
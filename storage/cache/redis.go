@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"crud/config"
+)
+
+// scanCount is how many keys DelPrefix asks SCAN for per call.
+const scanCount = 500
+
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to the server of cfg and checks that it answers.
+func NewRedis(ctx context.Context, cfg config.Config) (*Redis, error) {
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &Redis{
+		client: client,
+	}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+
+	value, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+
+	return value, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// DelPrefix walks the keyspace with SCAN rather than KEYS, so a flush does
+// not block the server.
+func (r *Redis) DelPrefix(ctx context.Context, prefix string) error {
+
+	iter := r.client.Scan(ctx, 0, prefix+"*", scanCount).Iterator()
+
+	var keys []string
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+
+		if len(keys) == scanCount {
+			err := r.client.Del(ctx, keys...).Err()
+			if err != nil {
+				return err
+			}
+
+			keys = keys[:0]
+		}
+	}
+
+	err := iter.Err()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		return r.client.Del(ctx, keys...).Err()
+	}
+
+	return nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import "errors"
+
+type ErrorKind string
+
+const (
+	KindNotFound   ErrorKind = "not_found"
+	KindConflict   ErrorKind = "conflict"
+	KindValidation ErrorKind = "validation"
+	// KindPrecondition means the If-Match version of the request is stale
+	KindPrecondition ErrorKind = "precondition"
+	KindInternal     ErrorKind = "internal"
+)
+
+// Error is returned by every StorageI implementation, so handlers can pick
+// the response status without knowing the backend.
+type Error struct {
+	Kind    ErrorKind
+	Field   string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewNotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func NewConflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func NewValidation(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+// NewInvalidField reports a validation error caused by one request field.
+func NewInvalidField(field, message string) *Error {
+	return &Error{Kind: KindValidation, Field: field, Message: message}
+}
+
+func NewInternal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal error", Err: err}
+}
+
+// KindOf returns the kind of err, KindInternal for errors that did not come
+// through a storage.Error.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindInternal
+}
+
+var (
+	ErrNotFound          = NewNotFound("not found")
+	ErrIllegalTransition = NewConflict("illegal order status transition")
+	ErrOrderItemsLocked  = NewConflict("order items cannot be changed once the order has shipped or is closed")
+	ErrCategoryCycle     = NewConflict("category cannot be moved under itself or its descendant")
+	ErrCategoryInUse     = NewConflict("category has child categories or products")
+	ErrVersionMismatch   = &Error{Kind: KindPrecondition, Message: "resource was modified, version does not match"}
+	// ErrMigrationsPending is returned by Ready while the schema is behind
+	ErrMigrationsPending = &Error{Kind: KindInternal, Message: "schema migrations are pending"}
+)
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+)
+
+// IndexedCategory is a category as seen by an import, deleted ones
+// included since their names stay taken.
+type IndexedCategory struct {
+	Id       string
+	Name     string
+	ParentID string
+	Deleted  bool
+}
+
+// CategoryIndex resolves the category references of an import against a
+// snapshot of the categories, and keeps track of the ones the import
+// creates. Both backends resolve through it so they agree on the rules.
+type CategoryIndex struct {
+	byId    map[string]*IndexedCategory
+	byName  map[string]*IndexedCategory
+	created []*IndexedCategory
+}
+
+func NewCategoryIndex(categories []*IndexedCategory) *CategoryIndex {
+
+	idx := &CategoryIndex{
+		byId:   make(map[string]*IndexedCategory, len(categories)),
+		byName: make(map[string]*IndexedCategory, len(categories)),
+	}
+
+	for _, c := range categories {
+		idx.byId[c.Id] = c
+		idx.byName[c.Name] = c
+	}
+
+	return idx
+}
+
+// Created returns the categories added since the index was built, each
+// after its parent.
+func (idx *CategoryIndex) Created() []*IndexedCategory {
+	return idx.created
+}
+
+// ByName returns the category named name, nil when there is none.
+func (idx *CategoryIndex) ByName(name string) *IndexedCategory {
+	return idx.byName[name]
+}
+
+// Add records a category created by the import and returns it.
+func (idx *CategoryIndex) Add(name, parentID string) *IndexedCategory {
+
+	c := &IndexedCategory{
+		Id:       uuid.New().String(),
+		Name:     name,
+		ParentID: parentID,
+	}
+
+	idx.byId[c.Id] = c
+	idx.byName[c.Name] = c
+	idx.created = append(idx.created, c)
+
+	return c
+}
+
+// Resolve finds the category ref stands for: a live category id, a name,
+// or a path of names from the root. Missing categories are created along
+// the path when create is set; nothing is created when ref fails to
+// resolve.
+func (idx *CategoryIndex) Resolve(ref string, create bool) (string, error) {
+
+	ref = strings.TrimSpace(ref)
+
+	if c, ok := idx.byId[ref]; ok {
+		if c.Deleted {
+			return "", fmt.Errorf("category %s is deleted", ref)
+		}
+
+		return c.Id, nil
+	}
+
+	var (
+		names    = strings.Split(ref, models.CategoryPathSeparator)
+		parent   *IndexedCategory
+		parentID string
+		missing  []string
+	)
+
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return "", fmt.Errorf("category path %q has an empty name", ref)
+		}
+
+		names[i] = name
+
+		c := idx.byName[name]
+
+		switch {
+		case c == nil:
+			missing = append(missing, name)
+			continue
+		case len(missing) > 0:
+			return "", fmt.Errorf("category %q already exists elsewhere, it cannot be created under %q", name, missing[len(missing)-1])
+		case c.Deleted:
+			return "", fmt.Errorf("category %q is deleted", name)
+		case len(names) > 1 && c.ParentID != parentID:
+			if parent == nil {
+				return "", fmt.Errorf("category %q is not a root category", name)
+			}
+			return "", fmt.Errorf("category %q is not under %q", name, parent.Name)
+		}
+
+		parent, parentID = c, c.Id
+	}
+
+	if len(missing) == 0 {
+		return parentID, nil
+	}
+
+	if !create {
+		return "", fmt.Errorf("category %q does not exist", missing[0])
+	}
+
+	for _, name := range missing {
+		parentID = idx.Add(name, parentID).Id
+	}
+
+	return parentID, nil
+}
+
+// PlanCategoryImport resolves the rows of req against idx, adding the
+// categories the import creates to idx. Nothing is written.
+func PlanCategoryImport(idx *CategoryIndex, req *models.ImportCategories) *models.ImportResult {
+
+	resp := &models.ImportResult{DryRun: req.DryRun}
+
+	for _, row := range req.Rows {
+		var (
+			parentID string
+			err      error
+		)
+
+		if strings.TrimSpace(row.Parent) != "" {
+			parentID, err = idx.Resolve(row.Parent, req.CreateMissing)
+			if err != nil {
+				resp.Errors = append(resp.Errors, models.ImportError{Line: row.Line, Field: "parent", Reason: err.Error()})
+				continue
+			}
+		}
+
+		c := idx.ByName(row.Name)
+
+		switch {
+		case c == nil:
+			idx.Add(row.Name, parentID)
+			resp.Created++
+		case c.Deleted:
+			resp.Errors = append(resp.Errors, models.ImportError{Line: row.Line, Field: "name", Reason: "name is taken by a deleted category"})
+			continue
+		case c.ParentID != parentID:
+			resp.Errors = append(resp.Errors, models.ImportError{Line: row.Line, Field: "name", Reason: "category already exists under another parent"})
+			continue
+		}
+
+		resp.Accepted++
+	}
+
+	resp.CreatedCategories = len(idx.Created()) - resp.Created
+
+	return resp
+}
+
+// PlanProductImport resolves the categories of the rows of req against
+// idx, adding the ones the import creates to idx. It returns the category
+// id of every row, empty for the rows with an error.
+func PlanProductImport(idx *CategoryIndex, req *models.ImportProducts) (*models.ImportResult, []string) {
+
+	var (
+		resp        = &models.ImportResult{DryRun: req.DryRun}
+		categoryIDs = make([]string, len(req.Rows))
+	)
+
+	for i, row := range req.Rows {
+		categoryID, err := idx.Resolve(row.Category, req.CreateMissing)
+		if err != nil {
+			resp.Errors = append(resp.Errors, models.ImportError{Line: row.Line, Field: "category", Reason: err.Error()})
+			continue
+		}
+
+		categoryIDs[i] = categoryID
+		resp.Accepted++
+		resp.Created++
+	}
+
+	resp.CreatedCategories = len(idx.Created())
+
+	return resp, categoryIDs
+}
@@ -0,0 +1,419 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type CategoryRepo struct {
+	db *database
+}
+
+func NewCategoryRepo(db *database) *CategoryRepo {
+	return &CategoryRepo{
+		db: db,
+	}
+}
+
+func (f *CategoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if err := f.checkName("", req.Name); err != nil {
+		return "", err
+	}
+
+	if req.ParentID != "" && !f.db.liveCategory(req.ParentID) {
+		return "", errCategoryParentFK
+	}
+
+	var (
+		id = uuid.New().String()
+		t  = now()
+	)
+
+	f.db.categories = append(f.db.categories, &category{
+		id:        id,
+		name:      req.Name,
+		parentID:  req.ParentID,
+		version:   1,
+		createdAt: t,
+		updatedAt: t,
+	})
+
+	return id, nil
+}
+
+func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrimaryKey) (*models.CategoryList, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	c := f.db.category(pkey.Id)
+	if c == nil || c.deletedAt != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	resp := toCategoryList(c)
+	resp.Childs = f.childs(c.id)
+
+	return resp, nil
+}
+
+func (f *CategoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp  = &models.GetListCategoryResponse{}
+		limit = req.Limit
+		roots []*category
+	)
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	for _, c := range f.db.categories {
+		if c.parentID == "" && c.deletedAt == nil {
+			roots = append(roots, c)
+		}
+	}
+
+	sort.SliceStable(roots, func(i, j int) bool {
+		return lessCreated(roots[i].createdAt, roots[i].id, roots[j].createdAt, roots[j].id)
+	})
+
+	key := func(i int) (time.Time, string) { return roots[i].createdAt, roots[i].id }
+
+	start, end, next := paginatePage(len(roots), key, req.Page, limit, false, true)
+	for _, c := range roots[start:end] {
+		category := toCategoryList(c)
+		category.Childs = f.childs(c.id)
+
+		resp.Categories = append(resp.Categories, category)
+	}
+
+	resp.NextCursor = next
+
+	if req.IncludeCount {
+		count := len(roots)
+		resp.Count = &count
+	}
+
+	return resp, nil
+}
+
+func (f *CategoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	c := f.db.category(req.Id)
+	if c == nil || c.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && c.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	if err := f.checkName(c.id, req.Name); err != nil {
+		return 0, err
+	}
+
+	if req.ParentID != "" && !f.db.liveCategory(req.ParentID) {
+		return 0, errCategoryParentFK
+	}
+
+	if req.ParentID != "" && f.isDescendant(req.ParentID, c.id) {
+		return 0, storage.ErrCategoryCycle
+	}
+
+	c.name = req.Name
+	c.parentID = req.ParentID
+	c.updatedAt = now()
+	c.version++
+
+	return 1, nil
+}
+func (f *CategoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	c := f.db.category(req.Id)
+	if c == nil || c.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && c.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	if req.Name.Set {
+		if err := f.checkName(c.id, req.Name.Value); err != nil {
+			return 0, err
+		}
+	}
+
+	if req.ParentID.Set && !req.ParentID.Null {
+		if !f.db.liveCategory(req.ParentID.Value) {
+			return 0, errCategoryParentFK
+		}
+
+		if f.isDescendant(req.ParentID.Value, c.id) {
+			return 0, storage.ErrCategoryCycle
+		}
+	}
+
+	if req.Name.Set {
+		c.name = req.Name.Value
+	}
+
+	// an explicit null leaves Value empty, which makes a root category
+	if req.ParentID.Set {
+		c.parentID = req.ParentID.Value
+	}
+
+	c.updatedAt = now()
+	c.version++
+
+	return 1, nil
+}
+
+func (f *CategoryRepo) Delete(ctx context.Context, req *models.DeleteCategory) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	c := f.db.category(req.Id)
+	if c == nil || c.deletedAt != nil {
+		return nil
+	}
+
+	if req.Version > 0 && c.version != req.Version {
+		return storage.ErrVersionMismatch
+	}
+
+	var (
+		t        = now()
+		products []*product
+		childs   []*category
+		deleted  = []string{c.id}
+	)
+
+	for _, p := range f.db.products {
+		if p.categoryID == c.id && p.deletedAt == nil {
+			products = append(products, p)
+		}
+	}
+
+	for _, child := range f.db.categories {
+		if child.parentID == c.id && child.deletedAt == nil {
+			childs = append(childs, child)
+		}
+	}
+
+	switch req.Policy {
+	case models.DeletePolicyCascade:
+		ids := f.db.descendants(c.id)
+
+		for _, p := range f.db.products {
+			if ids[p.categoryID] && p.deletedAt == nil {
+				p.deletedAt = &t
+			}
+		}
+
+		deleted = nil
+
+		for _, category := range f.db.categories {
+			if ids[category.id] {
+				category.deletedAt = &t
+				deleted = append(deleted, category.id)
+			}
+		}
+
+	case models.DeletePolicyReparent:
+		if len(products) > 0 && c.parentID == "" {
+			return storage.ErrCategoryInUse
+		}
+
+		for _, child := range childs {
+			child.parentID = c.parentID
+			child.updatedAt = t
+			child.version++
+		}
+
+		for _, p := range products {
+			p.categoryID = c.parentID
+			p.updatedAt = t
+			p.version++
+		}
+
+		c.deletedAt = &t
+
+	default:
+		if len(childs) > 0 || len(products) > 0 {
+			return storage.ErrCategoryInUse
+		}
+
+		c.deletedAt = &t
+	}
+
+	f.db.insertEvent(models.EventCategoryDeleted, c.id, models.CategoryDeletedEvent{
+		Id:          c.id,
+		ParentID:    c.parentID,
+		Policy:      req.Policy,
+		CategoryIDs: deleted,
+	})
+
+	return nil
+}
+
+// isDescendant reports whether id is ancestor itself or one of its
+// descendants, walking up the parents of id.
+func (f *CategoryRepo) isDescendant(id, ancestor string) bool {
+	seen := map[string]bool{}
+
+	for c := f.db.category(id); c != nil && !seen[c.id]; c = f.db.category(c.parentID) {
+		if c.id == ancestor {
+			return true
+		}
+
+		seen[c.id] = true
+	}
+
+	return false
+}
+
+// checkName enforces the UNIQUE constraint on categories.name, which like
+// the postgres index also covers soft deleted rows.
+func (f *CategoryRepo) checkName(id, name string) error {
+	for _, c := range f.db.categories {
+		if c.id != id && c.name == name {
+			return errCategoryNameExists
+		}
+	}
+
+	return nil
+}
+
+func (f *CategoryRepo) childs(parentID string) []*models.Category {
+	var childs []*models.Category
+
+	for _, c := range f.db.categories {
+		if c.parentID == parentID && c.deletedAt == nil {
+			childs = append(childs, toCategory(c))
+		}
+	}
+
+	return childs
+}
+
+func toCategory(c *category) *models.Category {
+	return &models.Category{
+		Id:        c.id,
+		Name:      c.name,
+		ParentID:  c.parentID,
+		CreatedAt: formatTime(c.createdAt),
+		UpdatedAt: formatTime(c.updatedAt),
+	}
+}
+
+func toCategoryList(c *category) *models.CategoryList {
+	return &models.CategoryList{
+		Id:        c.id,
+		Name:      c.name,
+		ParentID:  c.parentID,
+		Version:   c.version,
+		CreatedAt: formatTime(c.createdAt),
+		UpdatedAt: formatTime(c.updatedAt),
+	}
+}
+
+func (f *CategoryRepo) GetTree(ctx context.Context, req *models.GetCategoryTreeRequest) ([]*models.CategoryTree, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var roots []*models.CategoryTree
+
+	for _, c := range f.db.categories {
+		if c.deletedAt != nil {
+			continue
+		}
+
+		if (req.Id == "" && c.parentID == "") || (req.Id != "" && c.id == req.Id) {
+			roots = append(roots, f.subtree(c, 1, req.Depth, map[string]bool{}))
+		}
+	}
+
+	if req.Id != "" && len(roots) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return roots, nil
+}
+
+func (f *CategoryRepo) GetPath(ctx context.Context, pkey *models.CategoryPrimaryKey) (*models.CategoryPath, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		path = []*models.Category{}
+		seen = map[string]bool{}
+	)
+
+	c := f.db.category(pkey.Id)
+	for c != nil && c.deletedAt == nil && !seen[c.id] {
+		seen[c.id] = true
+		path = append([]*models.Category{toCategory(c)}, path...)
+
+		c = f.db.category(c.parentID)
+	}
+
+	if len(path) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return &models.CategoryPath{Categories: path}, nil
+}
+
+// subtree mirrors the recursive CTE: path guards against cycles and maxDepth
+// of zero means unlimited.
+func (f *CategoryRepo) subtree(c *category, depth int, maxDepth int32, path map[string]bool) *models.CategoryTree {
+
+	node := &models.CategoryTree{
+		Id:        c.id,
+		Name:      c.name,
+		ParentID:  c.parentID,
+		Depth:     depth,
+		CreatedAt: formatTime(c.createdAt),
+		UpdatedAt: formatTime(c.updatedAt),
+	}
+
+	if maxDepth > 0 && depth >= int(maxDepth) {
+		return node
+	}
+
+	path[c.id] = true
+	defer delete(path, c.id)
+
+	for _, child := range f.db.categories {
+		if child.parentID == c.id && child.deletedAt == nil && !path[child.id] {
+			node.Children = append(node.Children, f.subtree(child, depth+1, maxDepth, path))
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,14 @@
+package memory
+
+import "crud/storage"
+
+// The errors below mirror the constraint violations the postgres schema
+// raises, with the same storage.ErrorKind the postgres repositories map
+// them to.
+var (
+	errCategoryNameExists = storage.NewConflict(`duplicate key value violates unique constraint "categories_name_key"`)
+	errUserLoginExists    = storage.NewConflict(`duplicate key value violates unique constraint "users_login_key"`)
+	errCategoryParentFK   = storage.NewInvalidField("parent_id", "category does not exist")
+	errProductCategoryFK  = storage.NewInvalidField("category_id", "category does not exist")
+	errOrderUserFK        = storage.NewInvalidField("user_id", "user does not exist")
+)
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+
+	"crud/models"
+)
+
+func (f *OrderRepo) Export(ctx context.Context, req *models.GetListOrderRequest, fn func(*models.OrderExportRow) error) error {
+
+	list, err := f.GetList(ctx, &models.GetListOrderRequest{UserID: req.UserID})
+	if err != nil {
+		return err
+	}
+
+	for _, o := range list.Orders {
+		for _, item := range o.Items {
+			err = fn(&models.OrderExportRow{
+				OrderID:      o.Id,
+				UserID:       o.UserID,
+				Description:  o.Description,
+				Status:       o.Status,
+				Currency:     o.Currency,
+				Total:        o.Total,
+				CreatedAt:    o.CreatedAt,
+				ItemID:       item.Id,
+				ProductID:    item.Product.Id,
+				ProductName:  item.Product.Name,
+				CategoryID:   item.Product.Category.Id,
+				CategoryName: item.Product.Category.Name,
+				Quantity:     item.Quantity,
+				Price:        item.Price,
+				Subtotal:     item.Subtotal,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *ProductRepo) Export(ctx context.Context, req *models.GetListProductRequest, fn func(*models.ProductExportRow) error) error {
+
+	filters := *req
+	filters.Page = models.Page{}
+
+	list, err := f.GetList(ctx, &filters)
+	if err != nil {
+		return err
+	}
+
+	// the names are looked up first, fn may be slow to write them out
+	names := make(map[string]string)
+
+	f.db.mu.RLock()
+	for _, p := range list.Products {
+		if c := f.db.category(p.CategoryID); c != nil {
+			names[p.CategoryID] = c.name
+		}
+	}
+	f.db.mu.RUnlock()
+
+	for _, p := range list.Products {
+		err = fn(&models.ProductExportRow{
+			Id:            p.Id,
+			Name:          p.Name,
+			Price:         p.Price,
+			Currency:      p.Currency,
+			CategoryID:    p.CategoryID,
+			CategoryName:  names[p.CategoryID],
+			StockQuantity: p.StockQuantity,
+			CreatedAt:     p.CreatedAt,
+			UpdatedAt:     p.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+
+	"crud/models"
+)
+
+type IdempotencyRepo struct {
+	db *database
+}
+
+func NewIdempotencyRepo(db *database) *IdempotencyRepo {
+	return &IdempotencyRepo{
+		db: db,
+	}
+}
+
+func (f *IdempotencyRepo) Reserve(ctx context.Context, req *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if f.db.idempotencyKeys == nil {
+		f.db.idempotencyKeys = make(map[string]*idempotencyKey)
+	}
+
+	t := now()
+
+	// an expired key that was not purged yet is taken over like a new one
+	if k, ok := f.db.idempotencyKeys[idempotencyID(req)]; ok && k.expiresAt.After(t) {
+		return &models.IdempotencyKey{
+			UserID:      req.UserID,
+			Route:       req.Route,
+			Key:         req.Key,
+			RequestHash: k.requestHash,
+			StatusCode:  k.statusCode,
+			Response:    k.response,
+		}, nil
+	}
+
+	f.db.idempotencyKeys[idempotencyID(req)] = &idempotencyKey{
+		requestHash: req.RequestHash,
+		expiresAt:   t.Add(req.TTL),
+	}
+
+	return nil, nil
+}
+
+func (f *IdempotencyRepo) Complete(ctx context.Context, req *models.IdempotencyKey) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if k, ok := f.db.idempotencyKeys[idempotencyID(req)]; ok {
+		k.statusCode = req.StatusCode
+		k.response = req.Response
+	}
+
+	return nil
+}
+
+func (f *IdempotencyRepo) Release(ctx context.Context, req *models.IdempotencyKey) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if k, ok := f.db.idempotencyKeys[idempotencyID(req)]; ok && k.statusCode == 0 {
+		delete(f.db.idempotencyKeys, idempotencyID(req))
+	}
+
+	return nil
+}
+
+func (f *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	var (
+		purged int64
+		t      = now()
+	)
+
+	for id, k := range f.db.idempotencyKeys {
+		if !k.expiresAt.After(t) {
+			delete(f.db.idempotencyKeys, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// idempotencyID is the primary key of idempotency_keys.
+func idempotencyID(req *models.IdempotencyKey) string {
+	return req.UserID + "\x00" + req.Route + "\x00" + req.Key
+}
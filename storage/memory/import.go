@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+func (f *CategoryRepo) Import(ctx context.Context, req *models.ImportCategories) (*models.ImportResult, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	idx := f.db.categoryIndex()
+
+	resp := storage.PlanCategoryImport(idx, req)
+	if req.DryRun || len(resp.Errors) > 0 {
+		return resp, nil
+	}
+
+	f.db.insertCategories(idx.Created())
+	resp.Imported = true
+
+	return resp, nil
+}
+
+func (f *ProductRepo) Import(ctx context.Context, req *models.ImportProducts) (*models.ImportResult, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	idx := f.db.categoryIndex()
+
+	resp, categoryIDs := storage.PlanProductImport(idx, req)
+	if req.DryRun || len(resp.Errors) > 0 {
+		return resp, nil
+	}
+
+	f.db.insertCategories(idx.Created())
+
+	t := now()
+
+	for i, row := range req.Rows {
+		id := uuid.New().String()
+
+		f.db.products = append(f.db.products, &product{
+			id:         id,
+			name:       row.Name,
+			price:      row.Price,
+			currency:   row.Currency,
+			categoryID: categoryIDs[i],
+			stock:      row.StockQuantity,
+			version:    1,
+			createdAt:  t,
+			updatedAt:  t,
+		})
+
+		f.db.productPrices = append(f.db.productPrices, &productPrice{
+			id:            uuid.New().String(),
+			productID:     id,
+			price:         row.Price,
+			effectiveFrom: t,
+			createdAt:     t,
+		})
+
+		if row.StockQuantity > 0 {
+			f.db.insertMovement(id, "", row.StockQuantity, models.MovementRestock, "initial stock")
+		}
+	}
+
+	resp.Imported = true
+
+	return resp, nil
+}
+
+func (db *database) categoryIndex() *storage.CategoryIndex {
+
+	categories := make([]*storage.IndexedCategory, 0, len(db.categories))
+
+	for _, c := range db.categories {
+		categories = append(categories, &storage.IndexedCategory{
+			Id:       c.id,
+			Name:     c.name,
+			ParentID: c.parentID,
+			Deleted:  c.deletedAt != nil,
+		})
+	}
+
+	return storage.NewCategoryIndex(categories)
+}
+
+func (db *database) insertCategories(categories []*storage.IndexedCategory) {
+
+	t := now()
+
+	for _, c := range categories {
+		db.categories = append(db.categories, &category{
+			id:        c.Id,
+			name:      c.Name,
+			parentID:  c.ParentID,
+			version:   1,
+			createdAt: t,
+			updatedAt: t,
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+func (db *database) insertMovement(productID, orderID string, quantity int, reason, comment string) {
+	db.inventoryMovements = append(db.inventoryMovements, &inventoryMovement{
+		id:        uuid.New().String(),
+		productID: productID,
+		orderID:   orderID,
+		quantity:  quantity,
+		reason:    reason,
+		comment:   comment,
+		createdAt: now(),
+	})
+}
+
+// itemQuantities sums the lines per product, in product id order like the
+// postgres repositories lock them.
+func itemQuantities(items []*orderItem) ([]string, map[string]int) {
+	var (
+		quantities = make(map[string]int)
+		productIDs []string
+	)
+
+	for _, item := range items {
+		if _, ok := quantities[item.productID]; !ok {
+			productIDs = append(productIDs, item.productID)
+		}
+		quantities[item.productID] += item.quantity
+	}
+
+	sort.Strings(productIDs)
+
+	return productIDs, quantities
+}
+
+func (db *database) itemsOf(orderID string) []*orderItem {
+	var items []*orderItem
+
+	for _, item := range db.orderItems {
+		if item.orderID == orderID {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// checkStock fails the way the conditional UPDATE in postgres does when the
+// items need more than is on the shelf. freed holds the quantities that are
+// released in the same step, when an order's items are replaced.
+func (db *database) checkStock(items []*orderItem, freed map[string]int) error {
+	productIDs, quantities := itemQuantities(items)
+
+	for _, productID := range productIDs {
+		if db.product(productID).stock+freed[productID] < quantities[productID] {
+			return storage.NewConflict("not enough stock for product " + productID)
+		}
+	}
+
+	return nil
+}
+
+// reserveStock takes the items off the shelf, checkStock has to pass first.
+func (db *database) reserveStock(orderID string, items []*orderItem) {
+	productIDs, quantities := itemQuantities(items)
+
+	for _, productID := range productIDs {
+		db.product(productID).stock -= quantities[productID]
+		db.insertMovement(productID, orderID, -quantities[productID], models.MovementReserve, "")
+	}
+}
+
+// releaseStock puts the quantities reserved by the order back on the shelf.
+func (db *database) releaseStock(orderID string) {
+	productIDs, quantities := itemQuantities(db.itemsOf(orderID))
+
+	for _, productID := range productIDs {
+		db.product(productID).stock += quantities[productID]
+		db.insertMovement(productID, orderID, quantities[productID], models.MovementRelease, "")
+	}
+}
@@ -0,0 +1,411 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/storage"
+)
+
+type category struct {
+	id        string
+	name      string
+	parentID  string
+	version   int
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt *time.Time
+}
+
+type product struct {
+	id         string
+	name       string
+	price      decimal.Decimal
+	currency   string
+	categoryID string
+	stock      int
+	version    int
+	createdAt  time.Time
+	updatedAt  time.Time
+	deletedAt  *time.Time
+}
+
+type order struct {
+	id          string
+	userID      string
+	description string
+	status      string
+	currency    string
+	version     int
+	createdAt   time.Time
+	updatedAt   time.Time
+	deletedAt   *time.Time
+}
+
+type user struct {
+	id        string
+	login     string
+	password  string
+	role      string
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt *time.Time
+}
+
+type orderStatusHistory struct {
+	id         string
+	orderID    string
+	fromStatus string
+	toStatus   string
+	comment    string
+	createdAt  time.Time
+}
+
+type inventoryMovement struct {
+	id        string
+	productID string
+	orderID   string
+	quantity  int
+	reason    string
+	comment   string
+	createdAt time.Time
+}
+
+type productPrice struct {
+	id            string
+	productID     string
+	price         decimal.Decimal
+	effectiveFrom time.Time
+	effectiveTo   *time.Time
+	createdAt     time.Time
+}
+
+type idempotencyKey struct {
+	requestHash string
+	statusCode  int
+	response    []byte
+	expiresAt   time.Time
+}
+
+type outboxEvent struct {
+	id            string
+	eventType     string
+	aggregateID   string
+	payload       []byte
+	attempts      int
+	lastError     string
+	createdAt     time.Time
+	nextAttemptAt *time.Time
+	deliveredAt   *time.Time
+}
+
+type webhook struct {
+	id           string
+	userID       string
+	url          string
+	events       []string
+	secret       string
+	ownerOnly    bool
+	active       bool
+	failureCount int
+	disabledAt   *time.Time
+	createdAt    time.Time
+	updatedAt    time.Time
+	deletedAt    *time.Time
+}
+
+type webhookDelivery struct {
+	id            string
+	webhookID     string
+	eventID       string
+	eventType     string
+	payload       []byte
+	attempts      int
+	statusCode    int
+	lastError     string
+	createdAt     time.Time
+	nextAttemptAt *time.Time
+	deliveredAt   *time.Time
+}
+
+type orderItem struct {
+	id        string
+	orderID   string
+	productID string
+	quantity  int
+	price     decimal.Decimal
+	createdAt time.Time
+}
+
+// database keeps the tables in insertion order, the same order a sequential
+// scan returns them from postgres.
+type database struct {
+	mu         sync.RWMutex
+	categories []*category
+	products   []*product
+	orders     []*order
+	orderItems []*orderItem
+
+	orderStatusHistory []*orderStatusHistory
+	inventoryMovements []*inventoryMovement
+	productPrices      []*productPrice
+
+	idempotencyKeys map[string]*idempotencyKey
+	outbox          []*outboxEvent
+
+	webhooks          []*webhook
+	webhookDeliveries []*webhookDelivery
+
+	users []*user
+}
+
+type Store struct {
+	db       *database
+	category *CategoryRepo
+	product  *ProductRepo
+	order    *OrderRepo
+	user     *UserRepo
+
+	idempotency *IdempotencyRepo
+	outbox      *OutboxRepo
+	webhook     *WebhookRepo
+}
+
+func NewMemory() storage.StorageI {
+	db := &database{}
+
+	return &Store{
+		db:       db,
+		category: NewCategoryRepo(db),
+		product:  NewProductRepo(db),
+		order:    NewOrderRepo(db),
+		user:     NewUserRepo(db),
+
+		idempotency: NewIdempotencyRepo(db),
+		outbox:      NewOutboxRepo(db),
+		webhook:     NewWebhookRepo(db),
+	}
+}
+
+func (s *Store) CloseDB() {}
+
+func (s *Store) Ready(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+
+	if s.category == nil {
+		s.category = NewCategoryRepo(s.db)
+	}
+
+	return s.category
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+
+	if s.product == nil {
+		s.product = NewProductRepo(s.db)
+	}
+
+	return s.product
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+
+	if s.order == nil {
+		s.order = NewOrderRepo(s.db)
+	}
+
+	return s.order
+}
+
+func (s *Store) User() storage.UserRepoI {
+
+	if s.user == nil {
+		s.user = NewUserRepo(s.db)
+	}
+
+	return s.user
+}
+
+func (s *Store) Idempotency() storage.IdempotencyRepoI {
+
+	if s.idempotency == nil {
+		s.idempotency = NewIdempotencyRepo(s.db)
+	}
+
+	return s.idempotency
+}
+
+func (s *Store) Outbox() storage.OutboxRepoI {
+
+	if s.outbox == nil {
+		s.outbox = NewOutboxRepo(s.db)
+	}
+
+	return s.outbox
+}
+
+func (s *Store) Webhook() storage.WebhookRepoI {
+
+	if s.webhook == nil {
+		s.webhook = NewWebhookRepo(s.db)
+	}
+
+	return s.webhook
+}
+
+func (db *database) category(id string) *category {
+	for _, c := range db.categories {
+		if c.id == id {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (db *database) liveCategory(id string) bool {
+	c := db.category(id)
+	return c != nil && c.deletedAt == nil
+}
+
+// descendants returns the ids of the live category and all of its live
+// descendants, like the recursive CTE in the postgres repositories.
+func (db *database) descendants(id string) map[string]bool {
+	var (
+		ids   = map[string]bool{}
+		queue []string
+	)
+
+	if c := db.category(id); c != nil && c.deletedAt == nil {
+		ids[id] = true
+		queue = append(queue, id)
+	}
+
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+
+		for _, c := range db.categories {
+			if c.parentID == parentID && c.deletedAt == nil && !ids[c.id] {
+				ids[c.id] = true
+				queue = append(queue, c.id)
+			}
+		}
+	}
+
+	return ids
+}
+
+func (db *database) product(id string) *product {
+	for _, p := range db.products {
+		if p.id == id {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (db *database) order(id string) *order {
+	for _, o := range db.orders {
+		if o.id == id {
+			return o
+		}
+	}
+
+	return nil
+}
+
+func (db *database) user(id string) *user {
+	for _, u := range db.users {
+		if u.id == id {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+func paginate(total int, offset, limit int32) (int, int) {
+	start := int(offset)
+	if start > total {
+		start = total
+	}
+
+	end := total
+	if limit > 0 && start+int(limit) < total {
+		end = start + int(limit)
+	}
+
+	return start, end
+}
+
+// rowKey returns the (created_at, id) position of the i-th row of a list.
+type rowKey func(i int) (time.Time, string)
+
+// paginatePage is paginate for rows sorted by (created_at, id): it skips the
+// rows up to the cursor first and returns the next_cursor when keyset
+// paging applies and more rows follow.
+func paginatePage(total int, key rowKey, page models.Page, limit int32, desc, keyset bool) (int, int, string) {
+	first := 0
+	if page.Cursor != nil {
+		first = sort.Search(total, func(i int) bool {
+			createdAt, id := key(i)
+			return afterCursor(createdAt, id, page.Cursor, desc)
+		})
+	}
+
+	start, end := paginate(total-first, page.Offset, limit)
+	start += first
+	end += first
+
+	if !keyset || limit <= 0 || end == total || end == start {
+		return start, end, ""
+	}
+
+	createdAt, id := key(end - 1)
+
+	return start, end, helper.EncodeCursor(models.Cursor{CreatedAt: createdAt, Id: id})
+}
+
+func afterCursor(createdAt time.Time, id string, cursor *models.Cursor, desc bool) bool {
+	if !createdAt.Equal(cursor.CreatedAt) {
+		return createdAt.After(cursor.CreatedAt) != desc
+	}
+
+	if id == cursor.Id {
+		return false
+	}
+
+	return (id > cursor.Id) != desc
+}
+
+// lessCreated orders rows like ORDER BY created_at, id.
+func lessCreated(aCreatedAt time.Time, aId string, bCreatedAt time.Time, bId string) bool {
+	if !aCreatedAt.Equal(bCreatedAt) {
+		return aCreatedAt.Before(bCreatedAt)
+	}
+
+	return aId < bId
+}
@@ -0,0 +1,410 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type OrderRepo struct {
+	db *database
+}
+
+func NewOrderRepo(db *database) *OrderRepo {
+	return &OrderRepo{
+		db: db,
+	}
+}
+
+func (f *OrderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if req.UserID != "" && f.db.user(req.UserID) == nil {
+		return "", errOrderUserFK
+	}
+
+	var (
+		id = uuid.New().String()
+		t  = now()
+	)
+
+	items, currency, err := f.newItems(id, req.Items)
+	if err != nil {
+		return "", err
+	}
+
+	err = f.db.checkStock(items, nil)
+	if err != nil {
+		return "", err
+	}
+
+	f.db.orders = append(f.db.orders, &order{
+		id:          id,
+		userID:      req.UserID,
+		description: req.Description,
+		status:      models.OrderStatusPending,
+		currency:    currency,
+		version:     1,
+		createdAt:   t,
+		updatedAt:   t,
+	})
+	f.db.orderItems = append(f.db.orderItems, items...)
+	f.db.reserveStock(id, items)
+	f.insertHistory(id, "", models.OrderStatusPending, "")
+	f.insertOrderEvent(models.EventOrderCreated, f.db.order(id))
+
+	return id, nil
+}
+
+func (f *OrderRepo) GetByPKey(ctx context.Context, pkey *models.OrderPrimarKey) (*models.OrderList, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	o := f.db.order(pkey.Id)
+	if o == nil || o.deletedAt != nil {
+		return &models.OrderList{}, storage.ErrNotFound
+	}
+
+	resp := f.toOrderList(o)
+
+	for _, h := range f.db.orderStatusHistory {
+		if h.orderID == o.id {
+			resp.History = append(resp.History, models.OrderStatusHistory{
+				Id:         h.id,
+				FromStatus: h.fromStatus,
+				ToStatus:   h.toStatus,
+				Comment:    h.comment,
+				CreatedAt:  formatTime(h.createdAt),
+			})
+		}
+	}
+
+	return &resp, nil
+}
+
+func (f *OrderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp   = &models.GetListOrderResponse{}
+		orders []*order
+	)
+
+	for _, o := range f.db.orders {
+		if o.deletedAt == nil && (req.UserID == "" || o.userID == req.UserID) {
+			orders = append(orders, o)
+		}
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		return lessCreated(orders[i].createdAt, orders[i].id, orders[j].createdAt, orders[j].id)
+	})
+
+	key := func(i int) (time.Time, string) { return orders[i].createdAt, orders[i].id }
+
+	start, end, next := paginatePage(len(orders), key, req.Page, req.Limit, false, true)
+	for _, o := range orders[start:end] {
+		resp.Orders = append(resp.Orders, f.toOrderList(o))
+	}
+
+	resp.NextCursor = next
+
+	if req.IncludeCount {
+		count := len(orders)
+		resp.Count = &count
+	}
+
+	return resp, nil
+}
+
+func (f *OrderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	o := f.db.order(req.Id)
+	if o == nil || o.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && o.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	// items are replaced only when the request carries them
+	if len(req.Items) > 0 {
+		err := f.replaceItems(o, req.Items)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	o.description = req.Description
+	o.updatedAt = now()
+	o.version++
+
+	f.insertOrderEvent(models.EventOrderUpdated, o)
+
+	return 1, nil
+}
+
+func (f *OrderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	o := f.db.order(req.Id)
+	if o == nil || o.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && o.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	if req.Items.Set {
+		err := f.replaceItems(o, req.Items.Value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// an explicit null leaves Value empty, like a NULL description reads
+	if req.Description.Set {
+		o.description = req.Description.Value
+	}
+
+	o.updatedAt = now()
+	o.version++
+
+	f.insertOrderEvent(models.EventOrderUpdated, o)
+
+	return 1, nil
+}
+
+// replaceItems swaps all the lines of an order that has not shipped yet,
+// moving the reserved stock along.
+func (f *OrderRepo) replaceItems(o *order, req []models.CreateOrderItem) error {
+
+	if !models.OrderHoldsStock(o.status) {
+		return storage.ErrOrderItemsLocked
+	}
+
+	items, currency, err := f.newItems(o.id, req)
+	if err != nil {
+		return err
+	}
+
+	_, freed := itemQuantities(f.db.itemsOf(o.id))
+
+	err = f.db.checkStock(items, freed)
+	if err != nil {
+		return err
+	}
+
+	f.db.releaseStock(o.id)
+
+	var kept []*orderItem
+	for _, item := range f.db.orderItems {
+		if item.orderID != o.id {
+			kept = append(kept, item)
+		}
+	}
+
+	f.db.orderItems = append(kept, items...)
+	f.db.reserveStock(o.id, items)
+
+	o.currency = currency
+
+	return nil
+}
+
+func (f *OrderRepo) Delete(ctx context.Context, req *models.DeleteOrder) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	o := f.db.order(req.Id)
+	if o != nil && o.deletedAt == nil && req.Version > 0 && o.version != req.Version {
+		return storage.ErrVersionMismatch
+	}
+
+	if o != nil && o.deletedAt == nil {
+		t := now()
+		o.deletedAt = &t
+
+		if models.OrderHoldsStock(o.status) {
+			f.db.releaseStock(o.id)
+		}
+
+		f.insertOrderEvent(models.EventOrderUpdated, o)
+	}
+
+	return nil
+}
+
+func (f *OrderRepo) Transition(ctx context.Context, req *models.OrderTransition) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	o := f.db.order(req.Id)
+	if o == nil || o.deletedAt != nil {
+		return storage.ErrNotFound
+	}
+
+	if !models.CanTransitOrder(o.status, req.Status) {
+		return storage.ErrIllegalTransition
+	}
+
+	f.insertHistory(o.id, o.status, req.Status, req.Comment)
+
+	if models.OrderReleasesStock(o.status, req.Status) {
+		f.db.releaseStock(o.id)
+	}
+
+	o.status = req.Status
+	o.updatedAt = now()
+	o.version++
+
+	f.insertOrderEvent(models.EventOrderUpdated, o)
+
+	return nil
+}
+
+func (f *OrderRepo) insertHistory(orderID, from, to, comment string) {
+	f.db.orderStatusHistory = append(f.db.orderStatusHistory, &orderStatusHistory{
+		id:         uuid.New().String(),
+		orderID:    orderID,
+		fromStatus: from,
+		toStatus:   to,
+		comment:    comment,
+		createdAt:  now(),
+	})
+}
+
+// insertOrderEvent writes the order as it stands now into the outbox.
+func (f *OrderRepo) insertOrderEvent(eventType string, o *order) {
+
+	event := models.OrderEvent{
+		Id:          o.id,
+		UserID:      o.userID,
+		Description: o.description,
+		Status:      o.status,
+		Currency:    o.currency,
+		Total:       decimal.Zero,
+		Version:     o.version,
+		Deleted:     o.deletedAt != nil,
+	}
+
+	for _, item := range f.db.itemsOf(o.id) {
+		event.Items = append(event.Items, models.OrderEventItem{
+			ProductID: item.productID,
+			Quantity:  item.quantity,
+			Price:     item.price,
+		})
+		event.Total = event.Total.Add(item.price.Mul(decimal.NewFromInt(int64(item.quantity))))
+	}
+
+	f.db.insertEvent(eventType, o.id, event)
+}
+
+// newItems validates the requested lines and captures the current product
+// price and the order currency, leaving the tables untouched if any line
+// fails.
+func (f *OrderRepo) newItems(orderID string, req []models.CreateOrderItem) ([]*orderItem, string, error) {
+
+	var (
+		items    []*orderItem
+		currency string
+	)
+
+	if len(req) == 0 {
+		return nil, "", storage.NewInvalidField("items", "must contain at least 1 items")
+	}
+
+	for i, item := range req {
+		p := f.db.product(item.ProductID)
+		if p == nil || p.deletedAt != nil {
+			return nil, "", storage.NewInvalidField(fmt.Sprintf("items[%d].product_id", i), "product does not exist")
+		}
+
+		if item.Quantity <= 0 {
+			return nil, "", storage.NewInvalidField(fmt.Sprintf("items[%d].quantity", i), "must be greater than 0")
+		}
+
+		if currency != "" && p.currency != currency {
+			return nil, "", storage.NewInvalidField("items", "products must all have the same currency")
+		}
+		currency = p.currency
+
+		items = append(items, &orderItem{
+			id:        uuid.New().String(),
+			orderID:   orderID,
+			productID: p.id,
+			quantity:  item.Quantity,
+			price:     f.db.currentPrice(p),
+			createdAt: now(),
+		})
+	}
+
+	return items, currency, nil
+}
+
+// toOrderList resolves order_items -> products -> categories the way the
+// postgres repository joins them.
+func (f *OrderRepo) toOrderList(o *order) models.OrderList {
+
+	resp := models.OrderList{
+		Id:          o.id,
+		UserID:      o.userID,
+		Description: o.description,
+		Status:      o.status,
+		Currency:    o.currency,
+		Version:     o.version,
+		Total:       decimal.Zero,
+		CreatedAt:   formatTime(o.createdAt),
+	}
+
+	for _, item := range f.db.orderItems {
+		if item.orderID != o.id {
+			continue
+		}
+
+		p := f.db.product(item.productID)
+		c := f.db.category(p.categoryID)
+
+		subtotal := item.price.Mul(decimal.NewFromInt(int64(item.quantity)))
+
+		resp.Items = append(resp.Items, models.OrderItem{
+			Id: item.id,
+			Product: models.ProductList{
+				Id:   p.id,
+				Name: p.name,
+				Category: models.ProductCategory{
+					Id:       c.id,
+					Name:     c.name,
+					ParentID: c.parentID,
+				},
+			},
+			Quantity: item.quantity,
+			Price:    item.price,
+			Subtotal: subtotal,
+		})
+		resp.Total = resp.Total.Add(subtotal)
+	}
+
+	return resp
+}
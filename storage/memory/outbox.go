@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+)
+
+type OutboxRepo struct {
+	db *database
+}
+
+func NewOutboxRepo(db *database) *OutboxRepo {
+	return &OutboxRepo{
+		db: db,
+	}
+}
+
+// insertEvent appends a domain event to the outbox. It runs under the lock
+// of the change it describes, the way the postgres repositories write the
+// event in the same transaction.
+func (db *database) insertEvent(eventType, aggregateID string, payload interface{}) {
+
+	// the payloads are plain structs, Marshal does not fail on them
+	body, _ := json.Marshal(payload)
+
+	t := now()
+
+	db.outbox = append(db.outbox, &outboxEvent{
+		id:            uuid.New().String(),
+		eventType:     eventType,
+		aggregateID:   aggregateID,
+		payload:       body,
+		createdAt:     t,
+		nextAttemptAt: &t,
+	})
+}
+
+func (f *OutboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Event, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	var (
+		resp []*models.Event
+		t    = now()
+	)
+
+	for _, e := range f.db.outbox {
+		if len(resp) == limit {
+			break
+		}
+
+		if e.deliveredAt != nil || e.nextAttemptAt == nil || e.nextAttemptAt.After(t) {
+			continue
+		}
+
+		leased := t.Add(lease)
+		e.nextAttemptAt = &leased
+
+		resp = append(resp, &models.Event{
+			Id:          e.id,
+			Type:        e.eventType,
+			AggregateID: e.aggregateID,
+			Payload:     e.payload,
+			CreatedAt:   formatTime(e.createdAt),
+			Attempts:    e.attempts,
+		})
+	}
+
+	return resp, nil
+}
+
+func (f *OutboxRepo) MarkDelivered(ctx context.Context, id string) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if e := f.db.event(id); e != nil {
+		t := now()
+		e.attempts++
+		e.lastError = ""
+		e.deliveredAt = &t
+	}
+
+	return nil
+}
+
+func (f *OutboxRepo) MarkFailed(ctx context.Context, id string, reason string, retryAt *time.Time) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if e := f.db.event(id); e != nil {
+		e.attempts++
+		e.lastError = reason
+		e.nextAttemptAt = retryAt
+	}
+
+	return nil
+}
+
+func (db *database) event(id string) *outboxEvent {
+	for _, e := range db.outbox {
+		if e.id == id {
+			return e
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,335 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type ProductRepo struct {
+	db *database
+}
+
+func NewProductRepo(db *database) *ProductRepo {
+	return &ProductRepo{
+		db: db,
+	}
+}
+
+func (f *ProductRepo) Create(ctx context.Context, req *models.CreateProduct) (string, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	if !f.db.liveCategory(req.CategoryID) {
+		return "", errProductCategoryFK
+	}
+
+	var (
+		id = uuid.New().String()
+		t  = now()
+	)
+
+	f.db.products = append(f.db.products, &product{
+		id:         id,
+		name:       req.Name,
+		price:      req.Price,
+		currency:   req.Currency,
+		categoryID: req.CategoryID,
+		stock:      req.StockQuantity,
+		version:    1,
+		createdAt:  t,
+		updatedAt:  t,
+	})
+
+	f.db.productPrices = append(f.db.productPrices, &productPrice{
+		id:            uuid.New().String(),
+		productID:     id,
+		price:         req.Price,
+		effectiveFrom: t,
+		createdAt:     t,
+	})
+
+	if req.StockQuantity > 0 {
+		f.db.insertMovement(id, "", req.StockQuantity, models.MovementRestock, "initial stock")
+	}
+
+	return id, nil
+}
+
+func (f *ProductRepo) GetByPKey(ctx context.Context, pkey *models.ProductPrimarKey) (*models.Product, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	p := f.db.product(pkey.Id)
+	if p == nil || p.deletedAt != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	resp := f.db.toProduct(p)
+
+	return &resp, nil
+}
+
+func (f *ProductRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp     = &models.GetListProductResponse{}
+		products []*product
+	)
+
+	categories := map[string]bool{req.CategoryID: true}
+	if req.CategoryID != "" && req.IncludeDescendants {
+		categories = f.db.descendants(req.CategoryID)
+	}
+
+	search := strings.Fields(strings.ToLower(req.Search))
+
+	for _, p := range f.db.products {
+		if p.deletedAt != nil {
+			continue
+		}
+
+		if req.CategoryID != "" && !categories[p.categoryID] {
+			continue
+		}
+
+		price := f.db.currentPrice(p)
+		if (req.MinPrice != nil && price.LessThan(*req.MinPrice)) || (req.MaxPrice != nil && price.GreaterThan(*req.MaxPrice)) {
+			continue
+		}
+
+		if len(search) > 0 && !matchName(p.name, search) {
+			continue
+		}
+
+		products = append(products, p)
+	}
+
+	less := f.db.productLess(req.SortBy)
+
+	sort.SliceStable(products, func(i, j int) bool {
+		if req.SortOrder == models.SortDesc {
+			return less(products[j], products[i])
+		}
+
+		return less(products[i], products[j])
+	})
+
+	var (
+		keyset = req.SortBy == "" || req.SortBy == models.ProductSortCreatedAt
+		desc   = req.SortOrder == models.SortDesc
+		key    = func(i int) (time.Time, string) { return products[i].createdAt, products[i].id }
+	)
+
+	start, end, next := paginatePage(len(products), key, req.Page, req.Limit, desc, keyset)
+	for _, p := range products[start:end] {
+		resp.Products = append(resp.Products, f.db.toProduct(p))
+	}
+
+	resp.NextCursor = next
+
+	if req.IncludeCount {
+		count := len(products)
+		resp.Count = &count
+	}
+
+	return resp, nil
+}
+
+func (f *ProductRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	p := f.db.product(req.Id)
+	if p == nil || p.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && p.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	if !f.db.liveCategory(req.CategoryID) {
+		return 0, errProductCategoryFK
+	}
+
+	if !f.db.currentPrice(p).Equal(req.Price) {
+		f.db.schedulePrice(p, req.Price, nil)
+	}
+
+	p.name = req.Name
+	p.categoryID = req.CategoryID
+
+	if req.Currency != "" {
+		p.currency = req.Currency
+	}
+
+	p.updatedAt = now()
+	p.version++
+
+	return 1, nil
+}
+
+func (f *ProductRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	p := f.db.product(req.Id)
+	if p == nil || p.deletedAt != nil {
+		return 0, nil
+	}
+
+	if req.Version > 0 && p.version != req.Version {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	if req.CategoryID.Set && !f.db.liveCategory(req.CategoryID.Value) {
+		return 0, errProductCategoryFK
+	}
+
+	if req.Price.Set && !f.db.currentPrice(p).Equal(req.Price.Value) {
+		f.db.schedulePrice(p, req.Price.Value, nil)
+	}
+
+	if req.Name.Set {
+		p.name = req.Name.Value
+	}
+
+	if req.Currency.Set {
+		p.currency = req.Currency.Value
+	}
+
+	if req.CategoryID.Set {
+		p.categoryID = req.CategoryID.Value
+	}
+
+	p.updatedAt = now()
+	p.version++
+
+	return 1, nil
+}
+
+func (f *ProductRepo) Delete(ctx context.Context, req *models.DeleteProduct) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	p := f.db.product(req.Id)
+	if p != nil && p.deletedAt == nil && req.Version > 0 && p.version != req.Version {
+		return storage.ErrVersionMismatch
+	}
+
+	if p != nil && p.deletedAt == nil {
+		t := now()
+		p.deletedAt = &t
+	}
+
+	return nil
+}
+
+func (f *ProductRepo) Restock(ctx context.Context, req *models.Restock) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	p := f.db.product(req.ProductID)
+	if p == nil || p.deletedAt != nil {
+		return storage.ErrNotFound
+	}
+
+	p.stock += req.Quantity
+	p.updatedAt = now()
+	p.version++
+	f.db.insertMovement(p.id, "", req.Quantity, models.MovementRestock, req.Comment)
+
+	return nil
+}
+
+func (f *ProductRepo) GetMovements(ctx context.Context, req *models.GetListMovementRequest) (*models.GetListMovementResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp      = &models.GetListMovementResponse{}
+		movements []*inventoryMovement
+	)
+
+	// newest first, like ORDER BY created_at DESC
+	for i := len(f.db.inventoryMovements) - 1; i >= 0; i-- {
+		if m := f.db.inventoryMovements[i]; m.productID == req.ProductID {
+			movements = append(movements, m)
+		}
+	}
+
+	start, end := paginate(len(movements), req.Offset, req.Limit)
+	for _, m := range movements[start:end] {
+		resp.Movements = append(resp.Movements, models.InventoryMovement{
+			Id:        m.id,
+			ProductID: m.productID,
+			OrderID:   m.orderID,
+			Quantity:  m.quantity,
+			Reason:    m.reason,
+			Comment:   m.comment,
+			CreatedAt: formatTime(m.createdAt),
+		})
+	}
+
+	if len(resp.Movements) > 0 {
+		resp.Count = len(movements)
+	}
+
+	return resp, nil
+}
+
+func (db *database) productLess(sortBy string) func(a, b *product) bool {
+	switch sortBy {
+	case models.ProductSortPrice:
+		return func(a, b *product) bool { return db.currentPrice(a).LessThan(db.currentPrice(b)) }
+	case models.ProductSortName:
+		return func(a, b *product) bool { return a.name < b.name }
+	default:
+		return func(a, b *product) bool { return lessCreated(a.createdAt, a.id, b.createdAt, b.id) }
+	}
+}
+
+// matchName approximates the postgres search: every word of the query has to
+// appear in the name.
+func matchName(name string, words []string) bool {
+	name = strings.ToLower(name)
+
+	for _, word := range words {
+		if !strings.Contains(name, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (db *database) toProduct(p *product) models.Product {
+	return models.Product{
+		Id:            p.id,
+		Name:          p.name,
+		Price:         db.currentPrice(p),
+		Currency:      p.currency,
+		CategoryID:    p.categoryID,
+		StockQuantity: p.stock,
+		Version:       p.version,
+		CreatedAt:     formatTime(p.createdAt),
+		UpdatedAt:     formatTime(p.updatedAt),
+	}
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"crud/models"
+	"crud/storage"
+)
+
+func (f *ProductRepo) SchedulePrice(ctx context.Context, req *models.SchedulePrice) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	p := f.db.product(req.ProductID)
+	if p == nil || p.deletedAt != nil {
+		return storage.ErrNotFound
+	}
+
+	from := req.EffectiveFrom.UTC()
+	f.db.schedulePrice(p, req.Price, &from)
+
+	return nil
+}
+
+func (f *ProductRepo) GetPrices(ctx context.Context, pkey *models.ProductPrimarKey) (*models.ProductPriceHistory, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	resp := &models.ProductPriceHistory{Prices: []models.ProductPrice{}}
+
+	for _, pp := range f.db.pricesOf(pkey.Id) {
+		price := models.ProductPrice{
+			Id:            pp.id,
+			ProductID:     pp.productID,
+			Price:         pp.price,
+			EffectiveFrom: formatTime(pp.effectiveFrom),
+			CreatedAt:     formatTime(pp.createdAt),
+		}
+
+		if pp.effectiveTo != nil {
+			price.EffectiveTo = formatTime(*pp.effectiveTo)
+		}
+
+		resp.Prices = append(resp.Prices, price)
+	}
+
+	return resp, nil
+}
+
+// pricesOf returns the schedule of a product ordered by effective_from.
+func (db *database) pricesOf(productID string) []*productPrice {
+	var prices []*productPrice
+
+	for _, pp := range db.productPrices {
+		if pp.productID == productID {
+			prices = append(prices, pp)
+		}
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].effectiveFrom.Before(prices[j].effectiveFrom)
+	})
+
+	return prices
+}
+
+// currentPrice is the latest period that has started, falling back to the
+// list price for products without a schedule.
+func (db *database) currentPrice(p *product) decimal.Decimal {
+	var (
+		t     = now()
+		price = p.price
+	)
+
+	for _, pp := range db.pricesOf(p.id) {
+		if pp.effectiveFrom.After(t) {
+			break
+		}
+		price = pp.price
+	}
+
+	return price
+}
+
+// schedulePrice mirrors the postgres one: the period containing the change
+// point is cut there and the new price runs until the next scheduled change.
+// A nil from means now.
+func (db *database) schedulePrice(p *product, price decimal.Decimal, from *time.Time) {
+
+	at := now()
+	if from != nil {
+		at = *from
+	}
+
+	db.insertEvent(models.EventProductPriceChanged, p.id, models.ProductPriceEvent{
+		ProductID:     p.id,
+		Price:         price,
+		Currency:      p.currency,
+		EffectiveFrom: formatTime(at.UTC()),
+		Scheduled:     from != nil && at.After(now()),
+	})
+
+	var (
+		prices = db.pricesOf(p.id)
+		end    *time.Time
+		cut    bool
+	)
+
+	for _, pp := range prices {
+		if pp.effectiveFrom.After(at) || (pp.effectiveTo != nil && !pp.effectiveTo.After(at)) {
+			continue
+		}
+
+		if pp.effectiveFrom.Equal(at) {
+			pp.price = price
+			return
+		}
+
+		end = pp.effectiveTo
+		cut = true
+
+		t := at
+		pp.effectiveTo = &t
+	}
+
+	// the change comes before the first period, it runs until that one
+	if !cut && len(prices) > 0 {
+		t := prices[0].effectiveFrom
+		end = &t
+	}
+
+	db.productPrices = append(db.productPrices, &productPrice{
+		id:            uuid.New().String(),
+		productID:     p.id,
+		price:         price,
+		effectiveFrom: at,
+		effectiveTo:   end,
+		createdAt:     now(),
+	})
+
+	if from == nil {
+		p.price = price
+		p.updatedAt = now()
+		p.version++
+	}
+}
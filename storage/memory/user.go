@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type UserRepo struct {
+	db *database
+}
+
+func NewUserRepo(db *database) *UserRepo {
+	return &UserRepo{
+		db: db,
+	}
+}
+
+func (f *UserRepo) Create(ctx context.Context, req *models.CreateUser) (string, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	for _, u := range f.db.users {
+		if u.login == req.Login {
+			return "", errUserLoginExists
+		}
+	}
+
+	var (
+		id = uuid.New().String()
+		t  = now()
+	)
+
+	f.db.users = append(f.db.users, &user{
+		id:        id,
+		login:     req.Login,
+		password:  req.Password,
+		role:      req.Role,
+		createdAt: t,
+		updatedAt: t,
+	})
+
+	return id, nil
+}
+
+func (f *UserRepo) GetByPKey(ctx context.Context, pkey *models.UserPrimaryKey) (*models.User, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	for _, u := range f.db.users {
+		if u.deletedAt != nil {
+			continue
+		}
+
+		if (pkey.Login != "" && u.login == pkey.Login) || (pkey.Login == "" && u.id == pkey.Id) {
+			return &models.User{
+				Id:        u.id,
+				Login:     u.login,
+				Password:  u.password,
+				Role:      u.role,
+				CreatedAt: formatTime(u.createdAt),
+				UpdatedAt: formatTime(u.updatedAt),
+			}, nil
+		}
+	}
+
+	return nil, storage.ErrNotFound
+}
@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"crud/models"
+	"crud/storage"
+)
+
+type WebhookRepo struct {
+	db *database
+}
+
+func NewWebhookRepo(db *database) *WebhookRepo {
+	return &WebhookRepo{
+		db: db,
+	}
+}
+
+func (f *WebhookRepo) Create(ctx context.Context, req *models.CreateWebhook) (string, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	var (
+		id = uuid.New().String()
+		t  = now()
+	)
+
+	f.db.webhooks = append(f.db.webhooks, &webhook{
+		id:        id,
+		userID:    req.UserID,
+		url:       req.URL,
+		events:    append([]string(nil), req.Events...),
+		secret:    req.Secret,
+		ownerOnly: req.OwnerOnly,
+		active:    true,
+		createdAt: t,
+		updatedAt: t,
+	})
+
+	return id, nil
+}
+
+func (f *WebhookRepo) GetByPKey(ctx context.Context, pkey *models.WebhookPrimaryKey) (*models.Webhook, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	w := f.db.webhook(pkey.Id, pkey.UserID)
+	if w == nil {
+		return &models.Webhook{}, storage.ErrNotFound
+	}
+
+	return toWebhook(w), nil
+}
+
+func (f *WebhookRepo) GetList(ctx context.Context, req *models.GetListWebhookRequest) (*models.GetListWebhookResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp     = &models.GetListWebhookResponse{}
+		webhooks []*webhook
+	)
+
+	for _, w := range f.db.webhooks {
+		if w.deletedAt == nil && (req.UserID == "" || w.userID == req.UserID) {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	start, end := paginate(len(webhooks), req.Offset, req.Limit)
+	for _, w := range webhooks[start:end] {
+		resp.Webhooks = append(resp.Webhooks, toWebhook(w))
+	}
+
+	if len(resp.Webhooks) > 0 {
+		resp.Count = len(webhooks)
+	}
+
+	return resp, nil
+}
+
+func (f *WebhookRepo) Update(ctx context.Context, req *models.UpdateWebhook) (int64, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	w := f.db.webhook(req.Id, req.UserID)
+	if w == nil {
+		return 0, nil
+	}
+
+	w.url = req.URL
+	w.events = append([]string(nil), req.Events...)
+
+	if req.Secret != "" {
+		w.secret = req.Secret
+	}
+
+	// turning a webhook back on forgets the failures that disabled it
+	if req.Active != nil {
+		w.active = *req.Active
+
+		if w.active {
+			w.failureCount = 0
+			w.disabledAt = nil
+		}
+	}
+
+	w.updatedAt = now()
+
+	return 1, nil
+}
+
+func (f *WebhookRepo) Delete(ctx context.Context, req *models.WebhookPrimaryKey) error {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	w := f.db.webhook(req.Id, req.UserID)
+	if w == nil {
+		return storage.ErrNotFound
+	}
+
+	t := now()
+	w.active = false
+	w.deletedAt = &t
+
+	return nil
+}
+
+func (f *WebhookRepo) GetDeliveries(ctx context.Context, req *models.GetListWebhookDeliveryRequest) (*models.GetListWebhookDeliveryResponse, error) {
+
+	f.db.mu.RLock()
+	defer f.db.mu.RUnlock()
+
+	var (
+		resp       = &models.GetListWebhookDeliveryResponse{}
+		deliveries []*webhookDelivery
+	)
+
+	// newest first, like ORDER BY created_at DESC
+	for i := len(f.db.webhookDeliveries) - 1; i >= 0; i-- {
+		if d := f.db.webhookDeliveries[i]; d.webhookID == req.WebhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	start, end := paginate(len(deliveries), req.Offset, req.Limit)
+	for _, d := range deliveries[start:end] {
+		resp.Deliveries = append(resp.Deliveries, toWebhookDelivery(d))
+	}
+
+	if len(resp.Deliveries) > 0 {
+		resp.Count = len(deliveries)
+	}
+
+	return resp, nil
+}
+
+func (f *WebhookRepo) Enqueue(ctx context.Context, req *models.WebhookEvent) (int, error) {
+
+	body, err := json.Marshal(req.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	var queued int
+
+	for _, w := range f.db.webhooks {
+		if w.deletedAt != nil || !w.active || !subscribed(w, req.Event.Type) {
+			continue
+		}
+
+		// owner_only webhooks get the order events of their owner's orders only
+		if req.IsOrder && w.ownerOnly && w.userID != req.OrderUserID {
+			continue
+		}
+
+		// one delivery per webhook and event, like the unique index
+		if f.db.delivery(w.id, req.Event.Id) != nil {
+			continue
+		}
+
+		t := now()
+
+		f.db.webhookDeliveries = append(f.db.webhookDeliveries, &webhookDelivery{
+			id:            uuid.New().String(),
+			webhookID:     w.id,
+			eventID:       req.Event.Id,
+			eventType:     req.Event.Type,
+			payload:       body,
+			createdAt:     t,
+			nextAttemptAt: &t,
+		})
+		queued++
+	}
+
+	return queued, nil
+}
+
+func (f *WebhookRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	var (
+		resp []*models.WebhookDelivery
+		t    = now()
+	)
+
+	for _, d := range f.db.webhookDeliveries {
+		if len(resp) == limit {
+			break
+		}
+
+		if d.deliveredAt != nil || d.nextAttemptAt == nil || d.nextAttemptAt.After(t) {
+			continue
+		}
+
+		w := f.db.webhook(d.webhookID, "")
+		if w == nil || !w.active {
+			continue
+		}
+
+		leased := t.Add(lease)
+		d.nextAttemptAt = &leased
+
+		delivery := toWebhookDelivery(d)
+		delivery.URL = w.url
+		delivery.Secret = w.secret
+
+		resp = append(resp, delivery)
+	}
+
+	return resp, nil
+}
+
+func (f *WebhookRepo) RecordAttempt(ctx context.Context, req *models.WebhookAttempt) (bool, error) {
+
+	f.db.mu.Lock()
+	defer f.db.mu.Unlock()
+
+	d := f.db.deliveryById(req.DeliveryID)
+	w := f.db.webhook(req.WebhookID, "")
+	if d == nil || w == nil {
+		return false, nil
+	}
+
+	d.attempts++
+	d.statusCode = req.StatusCode
+
+	if req.Delivered {
+		t := now()
+		d.lastError = ""
+		d.nextAttemptAt = nil
+		d.deliveredAt = &t
+		w.failureCount = 0
+
+		return false, nil
+	}
+
+	d.lastError = req.Error
+	d.nextAttemptAt = req.RetryAt
+
+	w.failureCount++
+
+	if w.active && w.failureCount >= req.DisableAfter {
+		t := now()
+		w.active = false
+		w.disabledAt = &t
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// webhook finds a live webhook, of userID unless it is empty.
+func (db *database) webhook(id, userID string) *webhook {
+	for _, w := range db.webhooks {
+		if w.id == id && w.deletedAt == nil && (userID == "" || w.userID == userID) {
+			return w
+		}
+	}
+
+	return nil
+}
+
+func (db *database) delivery(webhookID, eventID string) *webhookDelivery {
+	for _, d := range db.webhookDeliveries {
+		if d.webhookID == webhookID && d.eventID == eventID {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (db *database) deliveryById(id string) *webhookDelivery {
+	for _, d := range db.webhookDeliveries {
+		if d.id == id {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func subscribed(w *webhook, eventType string) bool {
+	for _, e := range w.events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toWebhook(w *webhook) *models.Webhook {
+
+	resp := &models.Webhook{
+		Id:           w.id,
+		UserID:       w.userID,
+		URL:          w.url,
+		Events:       append([]string(nil), w.events...),
+		OwnerOnly:    w.ownerOnly,
+		Active:       w.active,
+		FailureCount: w.failureCount,
+		CreatedAt:    formatTime(w.createdAt),
+		UpdatedAt:    formatTime(w.updatedAt),
+	}
+
+	if w.disabledAt != nil {
+		resp.DisabledAt = formatTime(*w.disabledAt)
+	}
+
+	return resp
+}
+
+func toWebhookDelivery(d *webhookDelivery) *models.WebhookDelivery {
+
+	resp := &models.WebhookDelivery{
+		Id:         d.id,
+		WebhookID:  d.webhookID,
+		EventID:    d.eventID,
+		EventType:  d.eventType,
+		Payload:    d.payload,
+		Attempts:   d.attempts,
+		StatusCode: d.statusCode,
+		LastError:  d.lastError,
+		CreatedAt:  formatTime(d.createdAt),
+	}
+
+	if d.nextAttemptAt != nil {
+		resp.NextAttempt = formatTime(*d.nextAttemptAt)
+	}
+
+	if d.deliveredAt != nil {
+		resp.DeliveredAt = formatTime(*d.deliveredAt)
+	}
+
+	return resp
+}
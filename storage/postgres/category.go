@@ -3,13 +3,17 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"crud/models"
 	"crud/pkg/helper"
+	"crud/pkg/metrics"
+	"crud/storage"
 )
 
 type CategoryRepo struct {
@@ -23,6 +27,18 @@ func NewCategoryRepo(db *pgxpool.Pool) *CategoryRepo {
 }
 
 func (f *CategoryRepo) Create(ctx context.Context, category *models.CreateCategory) (string, error) {
+	defer metrics.ObserveQuery("category", "Create", time.Now())
+
+	if category.ParentID != "" {
+		ok, err := liveCategory(ctx, f.db, category.ParentID)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "", storage.NewInvalidField("parent_id", "category does not exist")
+		}
+	}
 
 	var (
 		id    = uuid.New().String()
@@ -45,18 +61,20 @@ func (f *CategoryRepo) Create(ctx context.Context, category *models.CreateCatego
 	)
 
 	if err != nil {
-		return "", err
+		return "", wrapError(err)
 	}
 
 	return id, nil
 }
 
 func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrimaryKey) (*models.CategoryList, error) {
+	defer metrics.ObserveQuery("category", "GetByPKey", time.Now())
 
 	var (
 		id        sql.NullString
 		name      sql.NullString
 		parentID  sql.NullString
+		version   sql.NullInt64
 		createdAt sql.NullString
 		updatedAt sql.NullString
 	)
@@ -66,6 +84,7 @@ func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrima
 			id,
 			name,
 			parent_id,
+			version,
 			created_at,
 			updated_at
 		FROM categories
@@ -76,18 +95,20 @@ func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrima
 		&id,
 		&name,
 		&parentID,
+		&version,
 		&createdAt,
 		&updatedAt,
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	resp := &models.CategoryList{
 		Id:        id.String,
 		Name:      name.String,
 		ParentID:  parentID.String,
+		Version:   int(version.Int64),
 		CreatedAt: createdAt.String,
 		UpdatedAt: updatedAt.String,
 	}
@@ -105,12 +126,9 @@ func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrima
 
 	rows, err := f.db.Query(ctx, queryChild, resp.Id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return resp, nil
-		}
-
-		return nil, err
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 
@@ -121,6 +139,9 @@ func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrima
 			&createdAt,
 			&updatedAt,
 		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
 
 		resp.Childs = append(resp.Childs, &models.Category{
 			Id:        id.String,
@@ -131,42 +152,65 @@ func (f *CategoryRepo) GetByPKey(ctx context.Context, pkey *models.CategoryPrima
 		})
 	}
 
-	return resp, err
+	return resp, wrapError(rows.Err())
 }
 
 func (f *CategoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+	defer metrics.ObserveQuery("category", "GetList", time.Now())
 
 	var (
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		resp   = &models.GetListCategoryResponse{}
+		resp  = &models.GetListCategoryResponse{}
+		where = " WHERE categories.parent_id IS NULL AND categories.deleted_at IS NULL"
+		limit = req.Limit
+		args  []interface{}
+		last  models.Cursor
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	if req.IncludeCount {
+		count, err := countRows(ctx, f.db, "SELECT COUNT(*) FROM categories"+where, args)
+		if err != nil {
+			return nil, err
+		}
+		resp.Count = &count
+	}
+
+	if req.Cursor != nil {
+		where += keysetWhere("categories", models.SortAsc, arg(req.Cursor.CreatedAt), arg(req.Cursor.Id))
 	}
 
 	query := `
 		SELECT
-			COUNT(*) OVER(),
 			id,
 			name,
 			parent_id,
+			version,
 			created_at,
-			updated_at
+			updated_at,
+			created_at
 		FROM categories
-		WHERE parent_id IS NULL AND deleted_at IS NULL
 	`
 
-	query += offset + limit
+	query += where + " ORDER BY categories.created_at, categories.id"
+
+	if req.Offset > 0 {
+		query += " OFFSET " + arg(req.Offset)
+	}
+
+	// one extra row tells whether there is a next page
+	query += " LIMIT " + arg(limit+1)
 
-	rows, err := f.db.Query(ctx, query)
+	rows, err := f.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	// get parent categories
@@ -176,120 +220,662 @@ func (f *CategoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 			id        sql.NullString
 			name      sql.NullString
 			parentID  sql.NullString
+			version   sql.NullInt64
 			createdAt sql.NullString
 			updatedAt sql.NullString
+			position  sql.NullTime
 		)
 
 		err = rows.Scan(
-			&resp.Count,
 			&id,
 			&name,
 			&parentID,
+			&version,
 			&createdAt,
 			&updatedAt,
+			&position,
 		)
+		if err != nil {
+			rows.Close()
+			return nil, wrapError(err)
+		}
+
+		if len(resp.Categories) == int(limit) {
+			resp.NextCursor = helper.EncodeCursor(last)
+			break
+		}
+
+		last = models.Cursor{CreatedAt: position.Time, Id: id.String}
 
 		resp.Categories = append(resp.Categories, &models.CategoryList{
 			Id:        id.String,
 			Name:      name.String,
 			ParentID:  parentID.String,
+			Version:   int(version.Int64),
 			CreatedAt: createdAt.String,
 			UpdatedAt: updatedAt.String,
 		})
 	}
 
-	// get category childs
-	for _, category := range resp.Categories {
+	if err = rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+	rows.Close()
 
-		queryChild := `
-			SELECT
-				id,
-				name,
-				parent_id,
-				created_at,
-				updated_at
-			FROM categories
-			WHERE parent_id = $1 AND deleted_at IS NULL
-		`
+	if len(resp.Categories) == 0 {
+		return resp, nil
+	}
 
-		rows, err := f.db.Query(ctx, queryChild, category.Id)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return resp, nil
-			}
+	// get category childs of the whole page with one query
+	var (
+		ids     = make([]string, 0, len(resp.Categories))
+		parents = make(map[string]*models.CategoryList, len(resp.Categories))
+	)
 
-			return nil, err
-		}
+	for _, category := range resp.Categories {
+		ids = append(ids, category.Id)
+		parents[category.Id] = category
+	}
 
-		for rows.Next() {
-			var (
-				id        sql.NullString
-				name      sql.NullString
-				parentID  sql.NullString
-				createdAt sql.NullString
-				updatedAt sql.NullString
-			)
+	queryChild := `
+		SELECT
+			id,
+			name,
+			parent_id,
+			created_at,
+			updated_at
+		FROM categories
+		WHERE parent_id = ANY($1::uuid[]) AND deleted_at IS NULL
+	`
 
-			err = rows.Scan(
-				&id,
-				&name,
-				&parentID,
-				&createdAt,
-				&updatedAt,
-			)
+	rows, err = f.db.Query(ctx, queryChild, ids)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			name      sql.NullString
+			parentID  sql.NullString
+			createdAt sql.NullString
+			updatedAt sql.NullString
+		)
 
-			category.Childs = append(category.Childs, &models.Category{
-				Id:        id.String,
-				Name:      name.String,
-				ParentID:  parentID.String,
-				CreatedAt: createdAt.String,
-				UpdatedAt: updatedAt.String,
-			})
+		err = rows.Scan(
+			&id,
+			&name,
+			&parentID,
+			&createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, wrapError(err)
 		}
+
+		parent := parents[parentID.String]
+		parent.Childs = append(parent.Childs, &models.Category{
+			Id:        id.String,
+			Name:      name.String,
+			ParentID:  parentID.String,
+			CreatedAt: createdAt.String,
+			UpdatedAt: updatedAt.String,
+		})
 	}
 
-	return resp, err
+	return resp, wrapError(rows.Err())
 }
 
+// categoryTreeLock serializes the transactions that move or delete
+// categories, so two concurrent moves cannot build a cycle together.
+const categoryTreeLock = 1001
+
 func (f *CategoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	defer metrics.ObserveQuery("category", "Update", time.Now())
 
 	var (
 		query  = ""
 		params map[string]interface{}
 	)
 
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", categoryTreeLock)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	if req.ParentID != "" {
+		ok, err := liveCategory(ctx, tx, req.ParentID)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			return 0, storage.NewInvalidField("parent_id", "category does not exist")
+		}
+
+		cycle, err := f.isDescendant(ctx, tx, req.ParentID, req.Id)
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		if cycle {
+			return 0, storage.ErrCategoryCycle
+		}
+	}
+
 	query = `
 		UPDATE
 			categories
 		SET
 			name = :name,
 			parent_id = :parent_id,
+			version = version + 1,
 			updated_at = now()
-		WHERE id = :id AND deleted_at IS NULL
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
 	`
 
 	params = map[string]interface{}{
 		"id":        req.Id,
 		"name":      req.Name,
 		"parent_id": helper.NewNullString(req.ParentID),
+		"version":   req.Version,
 	}
 
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	rowsAffected, err := f.db.Exec(ctx, query, args...)
+	rowsAffected, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	if rowsAffected.RowsAffected() == 0 && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "categories", req.Id)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return rowsAffected.RowsAffected(), nil
+}
+func (f *CategoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	defer metrics.ObserveQuery("category", "Patch", time.Now())
+
+	var (
+		set    []string
+		params = map[string]interface{}{
+			"id":      req.Id,
+			"version": req.Version,
+		}
+	)
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", categoryTreeLock)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	if req.Name.Set {
+		set = append(set, "name = :name")
+		params["name"] = req.Name.Value
+	}
+
+	if req.ParentID.Set && !req.ParentID.Null {
+		ok, err := liveCategory(ctx, tx, req.ParentID.Value)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			return 0, storage.NewInvalidField("parent_id", "category does not exist")
+		}
+
+		cycle, err := f.isDescendant(ctx, tx, req.ParentID.Value, req.Id)
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		if cycle {
+			return 0, storage.ErrCategoryCycle
+		}
+	}
+
+	if req.ParentID.Set {
+		set = append(set, "parent_id = :parent_id")
+		params["parent_id"] = patchNullString(req.ParentID)
+	}
+
+	query, args := helper.ReplaceQueryParams(patchQuery("categories", set), params)
+
+	rowsAffected, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	if rowsAffected.RowsAffected() == 0 && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "categories", req.Id)
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
-		return 0, err
+		return 0, wrapError(err)
 	}
 
 	return rowsAffected.RowsAffected(), nil
 }
 
-func (f *CategoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+func (f *CategoryRepo) Delete(ctx context.Context, req *models.DeleteCategory) error {
+	defer metrics.ObserveQuery("category", "Delete", time.Now())
+
+	var (
+		parentID sql.NullString
+		version  int
+	)
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", categoryTreeLock)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = tx.QueryRow(ctx,
+		"SELECT parent_id, version FROM categories WHERE id = $1 AND deleted_at IS NULL",
+		req.Id,
+	).Scan(&parentID, &version)
+
+	// deleting a missing or already deleted category is a no-op
+	if err == pgx.ErrNoRows {
+		return nil
+	}
 
-	_, err := f.db.Exec(ctx, "UPDATE categories SET deleted_at = now() WHERE id = $1", req.Id)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if req.Version > 0 && version != req.Version {
+		return storage.ErrVersionMismatch
+	}
+
+	deleted := []string{req.Id}
+
+	switch req.Policy {
+	case models.DeletePolicyCascade:
+		query := `
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = $1
+				UNION
+				SELECT categories.id FROM categories
+				JOIN tree ON categories.parent_id = tree.id
+				WHERE categories.deleted_at IS NULL
+			)
+			SELECT id FROM tree
+		`
+
+		var ids []string
+
+		rows, err := tx.Query(ctx, query, req.Id)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		for rows.Next() {
+			var id string
+
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return wrapError(err)
+			}
+
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return wrapError(err)
+		}
+
+		deleted = ids
+
+		_, err = tx.Exec(ctx,
+			"UPDATE products SET deleted_at = now() WHERE category_id = ANY($1::uuid[]) AND deleted_at IS NULL",
+			ids,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE categories SET deleted_at = now() WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL",
+			ids,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+	case models.DeletePolicyReparent:
+		hasProducts, err := f.hasProducts(ctx, tx, req.Id)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		// a root category has no parent to take over its products
+		if hasProducts && !parentID.Valid {
+			return storage.ErrCategoryInUse
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE categories SET parent_id = $2, version = version + 1, updated_at = now() WHERE parent_id = $1 AND deleted_at IS NULL",
+			req.Id,
+			parentID,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE products SET category_id = $2, version = version + 1, updated_at = now() WHERE category_id = $1 AND deleted_at IS NULL",
+			req.Id,
+			parentID,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE categories SET deleted_at = now() WHERE id = $1", req.Id)
+		if err != nil {
+			return wrapError(err)
+		}
+
+	default:
+		var hasChilds bool
+
+		err = tx.QueryRow(ctx,
+			"SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1 AND deleted_at IS NULL)",
+			req.Id,
+		).Scan(&hasChilds)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		hasProducts, err := f.hasProducts(ctx, tx, req.Id)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		if hasChilds || hasProducts {
+			return storage.ErrCategoryInUse
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE categories SET deleted_at = now() WHERE id = $1", req.Id)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	err = insertEvent(ctx, tx, models.EventCategoryDeleted, req.Id, models.CategoryDeletedEvent{
+		Id:          req.Id,
+		ParentID:    parentID.String,
+		Policy:      req.Policy,
+		CategoryIDs: deleted,
+	})
 	if err != nil {
 		return err
 	}
 
-	return err
+	return wrapError(tx.Commit(ctx))
+}
+
+// isDescendant reports whether id is ancestor itself or one of its
+// descendants, walking up the parents of id. UNION stops on existing cycles.
+func (f *CategoryRepo) isDescendant(ctx context.Context, tx pgx.Tx, id, ancestor string) (bool, error) {
+
+	var found bool
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT categories.id, categories.parent_id FROM categories
+			JOIN ancestors ON categories.id = ancestors.parent_id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)
+	`
+
+	err := tx.QueryRow(ctx, query, id, ancestor).Scan(&found)
+
+	return found, wrapError(err)
+}
+
+func (f *CategoryRepo) hasProducts(ctx context.Context, tx pgx.Tx, id string) (bool, error) {
+
+	var found bool
+
+	err := tx.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM products WHERE category_id = $1 AND deleted_at IS NULL)",
+		id,
+	).Scan(&found)
+
+	return found, wrapError(err)
+}
+
+// GetTree loads the whole hierarchy below the root categories, or below
+// req.Id, with one recursive query. Depth 1 returns the roots only, zero
+// means unlimited.
+func (f *CategoryRepo) GetTree(ctx context.Context, req *models.GetCategoryTreeRequest) ([]*models.CategoryTree, error) {
+	defer metrics.ObserveQuery("category", "GetTree", time.Now())
+
+	var (
+		roots []*models.CategoryTree
+		nodes = make(map[string]*models.CategoryTree)
+		start = "parent_id IS NULL AND $1 = ''"
+	)
+
+	if req.Id != "" {
+		start = "id = $1::uuid"
+	}
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT
+				id,
+				name,
+				parent_id,
+				created_at,
+				updated_at,
+				1 AS depth,
+				ARRAY[id] AS path
+			FROM categories
+			WHERE ` + start + ` AND deleted_at IS NULL
+
+			UNION ALL
+
+			SELECT
+				categories.id,
+				categories.name,
+				categories.parent_id,
+				categories.created_at,
+				categories.updated_at,
+				tree.depth + 1,
+				tree.path || categories.id
+			FROM categories
+			JOIN tree ON categories.parent_id = tree.id
+			WHERE categories.deleted_at IS NULL
+				AND NOT categories.id = ANY(tree.path)
+				AND ($2 <= 0 OR tree.depth < $2)
+		)
+		SELECT
+			id,
+			name,
+			parent_id,
+			depth,
+			created_at,
+			updated_at
+		FROM tree
+		ORDER BY depth, created_at
+	`
+
+	rows, err := f.db.Query(ctx, query, req.Id, req.Depth)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			name      sql.NullString
+			parentID  sql.NullString
+			depth     sql.NullInt64
+			createdAt sql.NullString
+			updatedAt sql.NullString
+		)
+
+		err = rows.Scan(
+			&id,
+			&name,
+			&parentID,
+			&depth,
+			&createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		node := &models.CategoryTree{
+			Id:        id.String,
+			Name:      name.String,
+			ParentID:  parentID.String,
+			Depth:     int(depth.Int64),
+			CreatedAt: createdAt.String,
+			UpdatedAt: updatedAt.String,
+		}
+		nodes[node.Id] = node
+
+		// rows come ordered by depth, so the parent is already known
+		if parent, ok := nodes[node.ParentID]; ok && node.Depth > 1 {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	if req.Id != "" && len(roots) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return roots, nil
+}
+
+// GetPath returns the breadcrumb of a category: its ancestors from the root
+// down to the category itself.
+func (f *CategoryRepo) GetPath(ctx context.Context, pkey *models.CategoryPrimaryKey) (*models.CategoryPath, error) {
+	defer metrics.ObserveQuery("category", "GetPath", time.Now())
+
+	var resp = &models.CategoryPath{}
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT
+				id,
+				name,
+				parent_id,
+				created_at,
+				updated_at,
+				0 AS level,
+				ARRAY[id] AS path
+			FROM categories
+			WHERE id = $1 AND deleted_at IS NULL
+
+			UNION ALL
+
+			SELECT
+				categories.id,
+				categories.name,
+				categories.parent_id,
+				categories.created_at,
+				categories.updated_at,
+				ancestors.level + 1,
+				ancestors.path || categories.id
+			FROM categories
+			JOIN ancestors ON categories.id = ancestors.parent_id
+			WHERE categories.deleted_at IS NULL
+				AND NOT categories.id = ANY(ancestors.path)
+		)
+		SELECT
+			id,
+			name,
+			parent_id,
+			created_at,
+			updated_at
+		FROM ancestors
+		ORDER BY level DESC
+	`
+
+	rows, err := f.db.Query(ctx, query, pkey.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			name      sql.NullString
+			parentID  sql.NullString
+			createdAt sql.NullString
+			updatedAt sql.NullString
+		)
+
+		err = rows.Scan(
+			&id,
+			&name,
+			&parentID,
+			&createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp.Categories = append(resp.Categories, &models.Category{
+			Id:        id.String,
+			Name:      name.String,
+			ParentID:  parentID.String,
+			CreatedAt: createdAt.String,
+			UpdatedAt: updatedAt.String,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	if len(resp.Categories) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return resp, nil
 }
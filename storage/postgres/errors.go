@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"crud/storage"
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// wrapError converts pgx and postgres errors to storage.Error. Errors that
+// are already storage errors pass through untouched. Malformed input such as
+// an invalid UUID is internal: the handlers validate it before it gets here.
+func wrapError(err error) error {
+
+	var (
+		storageErr *storage.Error
+		pgErr      *pgconn.PgError
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &storageErr):
+		return err
+	case errors.Is(err, pgx.ErrNoRows):
+		return storage.ErrNotFound
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return &storage.Error{Kind: storage.KindConflict, Message: pgErrorMessage(pgErr), Err: err}
+		case pgForeignKeyViolation, pgCheckViolation:
+			return &storage.Error{Kind: storage.KindValidation, Field: pgErrorField(pgErr), Message: pgErrorMessage(pgErr), Err: err}
+		}
+	}
+
+	return storage.NewInternal(err)
+}
+
+func pgErrorMessage(pgErr *pgconn.PgError) string {
+	if pgErr.Detail != "" {
+		return pgErr.Detail
+	}
+
+	return pgErr.Message
+}
+
+// pgDetailKey matches the column in details like
+// `Key (category_id)=(...) is not present in table "categories".`
+var pgDetailKey = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+func pgErrorField(pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName != "" {
+		return pgErr.ColumnName
+	}
+
+	if m := pgDetailKey.FindStringSubmatch(pgErr.Detail); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
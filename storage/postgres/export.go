@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/shopspring/decimal"
+
+	"crud/models"
+	"crud/pkg/metrics"
+)
+
+// exportFetchSize is the number of rows one FETCH of an export reads, all
+// an export holds in memory at a time.
+const exportFetchSize = 1000
+
+func (f *OrderRepo) Export(ctx context.Context, req *models.GetListOrderRequest, fn func(*models.OrderExportRow) error) error {
+	defer metrics.ObserveQuery("order", "Export", time.Now())
+
+	var (
+		where = " WHERE orders.deleted_at IS NULL"
+		args  []interface{}
+	)
+
+	if req.UserID != "" {
+		args = append(args, req.UserID)
+		where += " AND orders.user_id = $1"
+	}
+
+	query := `
+	SELECT
+		orders.id,
+		orders.user_id,
+		orders.description,
+		orders.status,
+		orders.currency,
+		COALESCE(SUM(order_items.price * order_items.quantity) OVER (PARTITION BY orders.id), 0),
+		orders.created_at,
+		order_items.id,
+		products.id,
+		products.name,
+		categories.id,
+		categories.name,
+		order_items.quantity,
+		order_items.price,
+		order_items.price * order_items.quantity
+	FROM
+		orders
+	LEFT JOIN order_items ON order_items.order_id = orders.id
+	LEFT JOIN products ON order_items.product_id = products.id
+	LEFT JOIN categories ON products.category_id = categories.id
+	` + where + `
+	ORDER BY orders.created_at, orders.id, order_items.created_at
+	`
+
+	return streamCursor(ctx, f.db, query, args, func(rows pgx.Rows) error {
+		var (
+			orderId      sql.NullString
+			userId       sql.NullString
+			description  sql.NullString
+			status       sql.NullString
+			currency     sql.NullString
+			total        decimal.NullDecimal
+			createdAt    sql.NullString
+			itemId       sql.NullString
+			productId    sql.NullString
+			productName  sql.NullString
+			categoryId   sql.NullString
+			categoryName sql.NullString
+			quantity     sql.NullInt64
+			price        decimal.NullDecimal
+			subtotal     decimal.NullDecimal
+		)
+
+		err := rows.Scan(
+			&orderId,
+			&userId,
+			&description,
+			&status,
+			&currency,
+			&total,
+			&createdAt,
+			&itemId,
+			&productId,
+			&productName,
+			&categoryId,
+			&categoryName,
+			&quantity,
+			&price,
+			&subtotal,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		return fn(&models.OrderExportRow{
+			OrderID:      orderId.String,
+			UserID:       userId.String,
+			Description:  description.String,
+			Status:       status.String,
+			Currency:     currency.String,
+			Total:        total.Decimal,
+			CreatedAt:    createdAt.String,
+			ItemID:       itemId.String,
+			ProductID:    productId.String,
+			ProductName:  productName.String,
+			CategoryID:   categoryId.String,
+			CategoryName: categoryName.String,
+			Quantity:     int(quantity.Int64),
+			Price:        price.Decimal,
+			Subtotal:     subtotal.Decimal,
+		})
+	})
+}
+
+func (f *ProductRepo) Export(ctx context.Context, req *models.GetListProductRequest, fn func(*models.ProductExportRow) error) error {
+	defer metrics.ObserveQuery("product", "Export", time.Now())
+
+	var (
+		where = " WHERE products.deleted_at IS NULL"
+		args  []interface{}
+	)
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where += productListWhere(req, arg)
+
+	_, order := productListOrder(req)
+
+	query := `
+		SELECT
+			products.id,
+			products.name,
+			` + productPrice + `,
+			products.currency,
+			products.category_id,
+			categories.name,
+			products.stock_quantity,
+			products.created_at,
+			products.updated_at
+		FROM
+			products
+		JOIN categories ON products.category_id = categories.id
+	` + where + order
+
+	return streamCursor(ctx, f.db, query, args, func(rows pgx.Rows) error {
+		var (
+			id           sql.NullString
+			name         sql.NullString
+			price        decimal.NullDecimal
+			currency     sql.NullString
+			categoryId   sql.NullString
+			categoryName sql.NullString
+			stock        sql.NullInt64
+			createdAt    sql.NullString
+			updatedAt    sql.NullString
+		)
+
+		err := rows.Scan(
+			&id,
+			&name,
+			&price,
+			&currency,
+			&categoryId,
+			&categoryName,
+			&stock,
+			&createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		return fn(&models.ProductExportRow{
+			Id:            id.String,
+			Name:          name.String,
+			Price:         price.Decimal,
+			Currency:      currency.String,
+			CategoryID:    categoryId.String,
+			CategoryName:  categoryName.String,
+			StockQuantity: int(stock.Int64),
+			CreatedAt:     createdAt.String,
+			UpdatedAt:     updatedAt.String,
+		})
+	})
+}
+
+// streamCursor runs query through a server side cursor and calls scan with
+// every row, fetching exportFetchSize rows at a time. The read only
+// REPEATABLE READ transaction keeps the whole export on one snapshot.
+func streamCursor(ctx context.Context, db *pgxpool.Pool, query string, args []interface{}, scan func(pgx.Rows) error) error {
+
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	fetch := "FETCH " + strconv.Itoa(exportFetchSize) + " FROM export_cursor"
+
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		var fetched int
+
+		for rows.Next() {
+			fetched++
+
+			err = scan(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return wrapError(err)
+		}
+
+		if fetched < exportFetchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"crud/models"
+	"crud/pkg/metrics"
+)
+
+type IdempotencyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewIdempotencyRepo(db *pgxpool.Pool) *IdempotencyRepo {
+	return &IdempotencyRepo{
+		db: db,
+	}
+}
+
+func (f *IdempotencyRepo) Reserve(ctx context.Context, req *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	defer metrics.ObserveQuery("idempotency", "Reserve", time.Now())
+
+	// an expired key that was not purged yet is taken over like a new one
+	query := `
+		INSERT INTO idempotency_keys(
+			user_id,
+			route,
+			key,
+			request_hash,
+			expires_at
+		) VALUES ( $1, $2, $3, $4, now() + $5::interval )
+		ON CONFLICT (user_id, route, key) DO UPDATE
+		SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response = NULL,
+			created_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+	`
+
+	result, err := f.db.Exec(ctx, query,
+		req.UserID,
+		req.Route,
+		req.Key,
+		req.RequestHash,
+		req.TTL,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if result.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var (
+		requestHash sql.NullString
+		statusCode  sql.NullInt64
+		response    []byte
+	)
+
+	query = `
+		SELECT
+			request_hash,
+			status_code,
+			response
+		FROM
+			idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3
+	`
+
+	err = f.db.QueryRow(ctx, query, req.UserID, req.Route, req.Key).Scan(
+		&requestHash,
+		&statusCode,
+		&response,
+	)
+
+	// the other request released the key in the meantime, it counts as
+	// still in progress so the client retries
+	if err == pgx.ErrNoRows {
+		return &models.IdempotencyKey{
+			UserID:      req.UserID,
+			Route:       req.Route,
+			Key:         req.Key,
+			RequestHash: req.RequestHash,
+		}, nil
+	}
+
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &models.IdempotencyKey{
+		UserID:      req.UserID,
+		Route:       req.Route,
+		Key:         req.Key,
+		RequestHash: requestHash.String,
+		StatusCode:  int(statusCode.Int64),
+		Response:    response,
+	}, nil
+}
+
+func (f *IdempotencyRepo) Complete(ctx context.Context, req *models.IdempotencyKey) error {
+	defer metrics.ObserveQuery("idempotency", "Complete", time.Now())
+
+	query := `
+		UPDATE
+			idempotency_keys
+		SET
+			status_code = $4,
+			response = $5
+		WHERE user_id = $1 AND route = $2 AND key = $3
+	`
+
+	_, err := f.db.Exec(ctx, query,
+		req.UserID,
+		req.Route,
+		req.Key,
+		req.StatusCode,
+		req.Response,
+	)
+
+	return wrapError(err)
+}
+
+func (f *IdempotencyRepo) Release(ctx context.Context, req *models.IdempotencyKey) error {
+	defer metrics.ObserveQuery("idempotency", "Release", time.Now())
+
+	_, err := f.db.Exec(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND route = $2 AND key = $3 AND status_code IS NULL",
+		req.UserID,
+		req.Route,
+		req.Key,
+	)
+
+	return wrapError(err)
+}
+
+func (f *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("idempotency", "PurgeExpired", time.Now())
+
+	result, err := f.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()")
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"crud/models"
+	"crud/pkg/metrics"
+	"crud/storage"
+)
+
+// importBatchSize is the number of rows written by one INSERT of an import.
+const importBatchSize = 1000
+
+func (f *CategoryRepo) Import(ctx context.Context, req *models.ImportCategories) (*models.ImportResult, error) {
+	defer metrics.ObserveQuery("category", "Import", time.Now())
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	idx, err := categoryIndex(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := storage.PlanCategoryImport(idx, req)
+	if req.DryRun || len(resp.Errors) > 0 {
+		return resp, nil
+	}
+
+	err = insertCategories(ctx, tx, idx.Created())
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	resp.Imported = true
+
+	return resp, nil
+}
+
+func (f *ProductRepo) Import(ctx context.Context, req *models.ImportProducts) (*models.ImportResult, error) {
+	defer metrics.ObserveQuery("product", "Import", time.Now())
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	idx, err := categoryIndex(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, categoryIDs := storage.PlanProductImport(idx, req)
+	if req.DryRun || len(resp.Errors) > 0 {
+		return resp, nil
+	}
+
+	err = insertCategories(ctx, tx, idx.Created())
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertProducts(ctx, tx, req.Rows, categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	resp.Imported = true
+
+	return resp, nil
+}
+
+// categoryIndex loads every category, deleted ones included, for the
+// import to resolve its references against.
+func categoryIndex(ctx context.Context, tx pgx.Tx) (*storage.CategoryIndex, error) {
+
+	rows, err := tx.Query(ctx, "SELECT id, name, COALESCE(parent_id::text, ''), deleted_at IS NOT NULL FROM categories")
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	var categories []*storage.IndexedCategory
+
+	for rows.Next() {
+		var c storage.IndexedCategory
+
+		err = rows.Scan(&c.Id, &c.Name, &c.ParentID, &c.Deleted)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		categories = append(categories, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return storage.NewCategoryIndex(categories), nil
+}
+
+// insertCategories writes the categories in batches. They come parents
+// first, and the foreign key is only checked at the end of each INSERT.
+func insertCategories(ctx context.Context, tx pgx.Tx, categories []*storage.IndexedCategory) error {
+
+	for start := 0; start < len(categories); start += importBatchSize {
+		var (
+			batch     = categories[start:batchEnd(start, len(categories))]
+			ids       = make([]string, len(batch))
+			names     = make([]string, len(batch))
+			parentIDs = make([]string, len(batch))
+		)
+
+		for i, c := range batch {
+			ids[i], names[i], parentIDs[i] = c.Id, c.Name, c.ParentID
+		}
+
+		query := `
+			INSERT INTO categories (
+				id,
+				name,
+				parent_id,
+				updated_at
+			)
+			SELECT
+				id::uuid,
+				name,
+				NULLIF(parent_id, '')::uuid,
+				now()
+			FROM unnest($1::text[], $2::text[], $3::text[]) AS r(id, name, parent_id)
+		`
+
+		_, err := tx.Exec(ctx, query, ids, names, parentIDs)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	return nil
+}
+
+// insertProducts writes the products in batches, with the opening price and
+// the initial stock movement Create writes for a single product.
+func insertProducts(ctx context.Context, tx pgx.Tx, products []*models.ImportProductRow, categoryIDs []string) error {
+
+	for start := 0; start < len(products); start += importBatchSize {
+		var (
+			end         = batchEnd(start, len(products))
+			ids         = make([]string, 0, end-start)
+			names       = make([]string, 0, end-start)
+			prices      = make([]string, 0, end-start)
+			currencies  = make([]string, 0, end-start)
+			categories  = make([]string, 0, end-start)
+			stocks      = make([]int32, 0, end-start)
+			priceIds    = make([]string, 0, end-start)
+			movementIds = make([]string, 0, end-start)
+		)
+
+		for i := start; i < end; i++ {
+			p := products[i]
+
+			ids = append(ids, uuid.New().String())
+			names = append(names, p.Name)
+			prices = append(prices, p.Price.String())
+			currencies = append(currencies, p.Currency)
+			categories = append(categories, categoryIDs[i])
+			stocks = append(stocks, int32(p.StockQuantity))
+			priceIds = append(priceIds, uuid.New().String())
+			movementIds = append(movementIds, uuid.New().String())
+		}
+
+		query := `
+			INSERT INTO products (
+				id,
+				name,
+				price,
+				currency,
+				category_id,
+				stock_quantity,
+				updated_at
+			)
+			SELECT
+				id::uuid,
+				name,
+				price::numeric,
+				currency,
+				category_id::uuid,
+				stock_quantity,
+				now()
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::int[])
+				AS r(id, name, price, currency, category_id, stock_quantity)
+		`
+
+		_, err := tx.Exec(ctx, query, ids, names, prices, currencies, categories, stocks)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		query = `
+			INSERT INTO product_prices (
+				id,
+				product_id,
+				price,
+				effective_from
+			)
+			SELECT
+				id::uuid,
+				product_id::uuid,
+				price::numeric,
+				now()
+			FROM unnest($1::text[], $2::text[], $3::text[]) AS r(id, product_id, price)
+		`
+
+		_, err = tx.Exec(ctx, query, priceIds, ids, prices)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		// the initial stock opens the ledger so it always sums up to stock_quantity
+		query = `
+			INSERT INTO inventory_movements (
+				id,
+				product_id,
+				quantity,
+				reason,
+				comment,
+				created_at
+			)
+			SELECT
+				id::uuid,
+				product_id::uuid,
+				quantity,
+				$4::varchar,
+				'initial stock',
+				clock_timestamp()
+			FROM unnest($1::text[], $2::text[], $3::int[]) AS r(id, product_id, quantity)
+			WHERE quantity > 0
+		`
+
+		_, err = tx.Exec(ctx, query, movementIds, ids, stocks, models.MovementRestock)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	return nil
+}
+
+func batchEnd(start, total int) int {
+	if start+importBatchSize < total {
+		return start + importBatchSize
+	}
+
+	return total
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/storage"
+)
+
+func insertMovement(ctx context.Context, tx pgx.Tx, productId, orderId string, quantity int, reason, comment string) error {
+
+	query := `
+		INSERT INTO inventory_movements(
+			id,
+			product_id,
+			order_id,
+			quantity,
+			reason,
+			comment,
+			created_at
+		) VALUES ( $1, $2, $3, $4, $5, $6, clock_timestamp() )
+	`
+
+	_, err := tx.Exec(ctx, query,
+		uuid.New().String(),
+		productId,
+		helper.NewNullString(orderId),
+		quantity,
+		reason,
+		helper.NewNullString(comment),
+	)
+
+	return wrapError(err)
+}
+
+// reserveStock takes the quantities of the order lines off the shelf. The
+// conditional UPDATE locks each product row and fails instead of going
+// below zero; products are locked in id order so two orders sharing
+// products cannot deadlock.
+func reserveStock(ctx context.Context, tx pgx.Tx, orderId string, items []models.CreateOrderItem) error {
+
+	var (
+		quantities = make(map[string]int)
+		productIds []string
+	)
+
+	for _, item := range items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIds = append(productIds, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	sort.Strings(productIds)
+
+	query := `
+		UPDATE
+			products
+		SET
+			stock_quantity = stock_quantity - $2
+		WHERE id = $1 AND deleted_at IS NULL AND stock_quantity >= $2
+	`
+
+	for _, productId := range productIds {
+		result, err := tx.Exec(ctx, query, productId, quantities[productId])
+		if err != nil {
+			return wrapError(err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return storage.NewConflict("not enough stock for product " + productId)
+		}
+
+		err = insertMovement(ctx, tx, productId, orderId, -quantities[productId], models.MovementReserve, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseStock puts the quantities reserved by the order back on the shelf.
+func releaseStock(ctx context.Context, tx pgx.Tx, orderId string) error {
+
+	var (
+		productIds []string
+		quantities []int
+	)
+
+	query := `
+		SELECT
+			product_id,
+			SUM(quantity)
+		FROM order_items
+		WHERE order_id = $1
+		GROUP BY product_id
+		ORDER BY product_id
+	`
+
+	rows, err := tx.Query(ctx, query, orderId)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	for rows.Next() {
+		var (
+			productId string
+			quantity  int
+		)
+
+		err = rows.Scan(&productId, &quantity)
+		if err != nil {
+			rows.Close()
+			return wrapError(err)
+		}
+
+		productIds = append(productIds, productId)
+		quantities = append(quantities, quantity)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return wrapError(err)
+	}
+
+	for i, productId := range productIds {
+		_, err = tx.Exec(ctx,
+			"UPDATE products SET stock_quantity = stock_quantity + $2 WHERE id = $1",
+			productId,
+			quantities[i],
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		err = insertMovement(ctx, tx, productId, orderId, quantities[i], models.MovementRelease, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
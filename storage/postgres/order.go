@@ -4,12 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/shopspring/decimal"
 
 	"crud/models"
 	"crud/pkg/helper"
+	"crud/pkg/metrics"
+	"crud/storage"
 )
 
 type OrderRepo struct {
@@ -23,213 +29,821 @@ func NewOrderRepo(db *pgxpool.Pool) *OrderRepo {
 }
 
 func (f *OrderRepo) Create(ctx context.Context, order *models.CreateOrder) (string, error) {
+	defer metrics.ObserveQuery("order", "Create", time.Now())
 
 	var (
 		id    = uuid.New().String()
 		query string
 	)
 
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
 	query = `
 		INSERT INTO orders(
 			id,
+			user_id,
 			description,
-			product_id,
 			updated_at
 		) VALUES ( $1, $2, $3, now() )
 	`
-	_, err := f.db.Exec(ctx, query,
+	_, err = tx.Exec(ctx, query,
 		id,
+		helper.NewNullString(order.UserID),
 		order.Description,
-		order.Product_id,
 	)
 
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	err = f.insertItems(ctx, tx, id, order.Items)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	err = reserveStock(ctx, tx, id, order.Items)
 	if err != nil {
 		return "", err
 	}
 
+	err = f.insertHistory(ctx, tx, id, "", models.OrderStatusPending, "")
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	err = f.insertOrderEvent(ctx, tx, models.EventOrderCreated, id)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	return id, nil
 }
 
 func (f *OrderRepo) GetByPKey(ctx context.Context, pkey *models.OrderPrimarKey) (*models.OrderList, error) {
+	defer metrics.ObserveQuery("order", "GetByPKey", time.Now())
 
 	var (
-		productCategory models.ProductCategory
-		productList     models.ProductList
-		orderList       models.OrderList
+		orderList models.OrderList
 
 		orderId          sql.NullString
+		orderUserId      sql.NullString
 		orderDescription sql.NullString
-		productId        sql.NullString
-		productName      sql.NullString
-		categoryId       sql.NullString
-		categoryName     sql.NullString
-		categoryParentId sql.NullString
+		orderStatus      sql.NullString
+		orderCurrency    sql.NullString
+		orderVersion     sql.NullInt64
+		orderCreatedAt   sql.NullString
 	)
 
 	query := `
 	SELECT
 		orders.id,
+		orders.user_id,
 		orders.description,
-		products.id,
-		products.name,
-		categories.id,
-		categories.name,
-		categories.parent_id
+		orders.status,
+		orders.currency,
+		orders.version,
+		orders.created_at
 	FROM
     	orders
-	JOIN products ON orders.product_id = products.id
-	JOIN categories ON products.category_id = categories.id
-	WHERE orders.deleted_at IS NULL AND products.deleted_at IS NULL AND categories.deleted_at IS NULL AND orders.id = $1
+	WHERE orders.deleted_at IS NULL AND orders.id = $1
 	`
 
 	err := f.db.QueryRow(ctx, query, pkey.Id).Scan(
 		&orderId,
+		&orderUserId,
 		&orderDescription,
-		&productId,
-		&productName,
-		&categoryId,
-		&categoryName,
-		&categoryParentId,
+		&orderStatus,
+		&orderCurrency,
+		&orderVersion,
+		&orderCreatedAt,
 	)
 
-	productCategory.Id = categoryId.String
-	productCategory.Name = categoryName.String
-	productCategory.ParentID = categoryParentId.String
-
-	productList.Id = productId.String
-	productList.Name = productName.String
-	productList.Category = productCategory
+	if err != nil {
+		return &orderList, wrapError(err)
+	}
 
 	orderList.Id = orderId.String
+	orderList.UserID = orderUserId.String
 	orderList.Description = orderDescription.String
-	orderList.Product = productList
+	orderList.Status = orderStatus.String
+	orderList.Currency = orderCurrency.String
+	orderList.Version = int(orderVersion.Int64)
+	orderList.CreatedAt = orderCreatedAt.String
+
+	items, err := f.getItems(ctx, []string{orderList.Id})
+	if err != nil {
+		return &orderList, wrapError(err)
+	}
+
+	orderList.Items = items[orderList.Id]
+	orderList.Total = orderTotal(orderList.Items)
+
+	orderList.History, err = f.getHistory(ctx, orderList.Id)
+	if err != nil {
+		return &orderList, wrapError(err)
+	}
 
-	return &orderList, err
+	return &orderList, nil
 }
 
 func (f *OrderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+	defer metrics.ObserveQuery("order", "GetList", time.Now())
 
 	var (
-		resp   = models.GetListOrderResponse{}
-		offset = ""
-		limit  = ""
+		resp  = models.GetListOrderResponse{}
+		where = " WHERE orders.deleted_at IS NULL"
+		args  []interface{}
+		ids   []string
+		last  models.Cursor
 	)
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
 	}
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if req.UserID != "" {
+		where += " AND orders.user_id = " + arg(req.UserID)
+	}
+
+	if req.IncludeCount {
+		count, err := countRows(ctx, f.db, "SELECT COUNT(*) FROM orders"+where, args)
+		if err != nil {
+			return nil, err
+		}
+		resp.Count = &count
+	}
+
+	if req.Cursor != nil {
+		where += keysetWhere("orders", models.SortAsc, arg(req.Cursor.CreatedAt), arg(req.Cursor.Id))
 	}
 
 	query := `
 	SELECT
-		COUNT(*) OVER(),
 		orders.id,
+		orders.user_id,
 		orders.description,
-		products.id,
-		products.name,
-		categories.id,
-		categories.name,
-		categories.parent_id
+		orders.status,
+		orders.currency,
+		orders.version,
+		orders.created_at,
+		orders.created_at
 	FROM
     	orders
-	JOIN products ON orders.product_id = products.id
-	JOIN categories ON products.category_id = categories.id
-	WHERE orders.deleted_at IS NULL AND products.deleted_at IS NULL AND categories.deleted_at IS NULL
 	`
 
-	query += offset + limit
+	query += where + " ORDER BY orders.created_at, orders.id"
 
-	rows, err := f.db.Query(ctx, query)
+	if req.Offset > 0 {
+		query += " OFFSET " + arg(req.Offset)
+	}
+
+	// one extra row tells whether there is a next page
+	if req.Limit > 0 {
+		query += " LIMIT " + arg(req.Limit+1)
+	}
+
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
-			productCategory models.ProductCategory
-			productList     models.ProductList
-
 			orderId          sql.NullString
+			orderUserId      sql.NullString
 			orderDescription sql.NullString
-			productId        sql.NullString
-			productName      sql.NullString
-			categoryId       sql.NullString
-			categoryName     sql.NullString
-			categoryParentId sql.NullString
+			orderStatus      sql.NullString
+			orderCurrency    sql.NullString
+			orderVersion     sql.NullInt64
+			orderCreatedAt   sql.NullString
+			position         sql.NullTime
 		)
 
 		err := rows.Scan(
-			&resp.Count,
 			&orderId,
+			&orderUserId,
 			&orderDescription,
-			&productId,
-			&productName,
-			&categoryId,
-			&categoryName,
-			&categoryParentId,
+			&orderStatus,
+			&orderCurrency,
+			&orderVersion,
+			&orderCreatedAt,
+			&position,
 		)
 		if err != nil {
-			return nil, err
+			return nil, wrapError(err)
+		}
+
+		if req.Limit > 0 && len(resp.Orders) == int(req.Limit) {
+			resp.NextCursor = helper.EncodeCursor(last)
+			break
 		}
 
-		productCategory.Id = categoryId.String
-		productCategory.Name = categoryName.String
-		productCategory.ParentID = categoryParentId.String
+		last = models.Cursor{CreatedAt: position.Time, Id: orderId.String}
 
-		productList.Id = productId.String
-		productList.Name = productName.String
-		productList.Category = productCategory
+		ids = append(ids, orderId.String)
 
 		resp.Orders = append(resp.Orders, models.OrderList{
 			Id:          orderId.String,
+			UserID:      orderUserId.String,
 			Description: orderDescription.String,
-			Product:     productList,
+			Status:      orderStatus.String,
+			Currency:    orderCurrency.String,
+			Version:     int(orderVersion.Int64),
+			CreatedAt:   orderCreatedAt.String,
 		})
 
 	}
 
-	return &resp, err
+	if err = rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	if len(ids) == 0 {
+		return &resp, nil
+	}
+
+	// load the items of the whole page with one query
+	items, err := f.getItems(ctx, ids)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	for i := range resp.Orders {
+		resp.Orders[i].Items = items[resp.Orders[i].Id]
+		resp.Orders[i].Total = orderTotal(resp.Orders[i].Items)
+	}
+
+	return &resp, nil
 }
 
 func (f *OrderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	defer metrics.ObserveQuery("order", "Update", time.Now())
 
 	var (
 		query  = ""
 		params map[string]interface{}
+		status sql.NullString
 	)
 
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
 	query = `
 		UPDATE
 			orders
 		SET
 			description = :description,
-			product_id = :product_id,
+			version = version + 1,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
+		RETURNING status
 	`
 
 	params = map[string]interface{}{
 		"id":          req.Id,
 		"description": req.Description,
-		"product_id":  req.Product_id,
+		"version":     req.Version,
 	}
 
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	rowsAffected, err := f.db.Exec(ctx, query, args...)
+	err = tx.QueryRow(ctx, query, args...).Scan(&status)
+	if err == pgx.ErrNoRows && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "orders", req.Id)
+	}
+
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	// items are replaced only when the request carries them
+	if len(req.Items) > 0 {
+		err = f.replaceItems(ctx, tx, req.Id, status.String, req.Items)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = f.insertOrderEvent(ctx, tx, models.EventOrderUpdated, req.Id)
 	if err != nil {
 		return 0, err
 	}
 
-	return rowsAffected.RowsAffected(), nil
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return 1, nil
 }
 
-func (f *OrderRepo) Delete(ctx context.Context, req *models.OrderPrimarKey) error {
+func (f *OrderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	defer metrics.ObserveQuery("order", "Patch", time.Now())
+
+	var (
+		set    []string
+		status sql.NullString
+		params = map[string]interface{}{
+			"id":      req.Id,
+			"version": req.Version,
+		}
+	)
+
+	if req.Description.Set {
+		set = append(set, "description = :description")
+		params["description"] = patchNullString(req.Description)
+	}
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args := helper.ReplaceQueryParams(patchQuery("orders", set)+" RETURNING status", params)
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&status)
+	if err == pgx.ErrNoRows && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "orders", req.Id)
+	}
+
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
 
-	_, err := f.db.Exec(ctx, "UPDATE orders SET deleted_at = now() WHERE id = $1", req.Id)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	if req.Items.Set {
+		err = f.replaceItems(ctx, tx, req.Id, status.String, req.Items.Value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = f.insertOrderEvent(ctx, tx, models.EventOrderUpdated, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return 1, nil
+}
+
+// replaceItems swaps all the lines of an order that has not shipped yet,
+// moving the reserved stock along.
+func (f *OrderRepo) replaceItems(ctx context.Context, tx pgx.Tx, orderId string, status string, items []models.CreateOrderItem) error {
+
+	if !models.OrderHoldsStock(status) {
+		return storage.ErrOrderItemsLocked
+	}
+
+	err := releaseStock(ctx, tx, orderId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM order_items WHERE order_id = $1", orderId)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = f.insertItems(ctx, tx, orderId, items)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return reserveStock(ctx, tx, orderId, items)
+}
+
+func (f *OrderRepo) Delete(ctx context.Context, req *models.DeleteOrder) error {
+	defer metrics.ObserveQuery("order", "Delete", time.Now())
+
+	var status sql.NullString
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		"UPDATE orders SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2) RETURNING status",
+		req.Id,
+		req.Version,
+	).Scan(&status)
+	if err == pgx.ErrNoRows && req.Version > 0 {
+		return checkVersion(ctx, tx, "orders", req.Id)
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if models.OrderHoldsStock(status.String) {
+		err = releaseStock(ctx, tx, req.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.insertOrderEvent(ctx, tx, models.EventOrderUpdated, req.Id)
 	if err != nil {
 		return err
 	}
 
-	return err
+	return wrapError(tx.Commit(ctx))
+}
+
+func (f *OrderRepo) Transition(ctx context.Context, req *models.OrderTransition) error {
+	defer metrics.ObserveQuery("order", "Transition", time.Now())
+
+	var status sql.NullString
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the row so concurrent transitions are applied one after another
+	err = tx.QueryRow(ctx,
+		"SELECT status FROM orders WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		req.Id,
+	).Scan(&status)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if !models.CanTransitOrder(status.String, req.Status) {
+		return storage.ErrIllegalTransition
+	}
+
+	_, err = tx.Exec(ctx,
+		"UPDATE orders SET status = $2, version = version + 1, updated_at = now() WHERE id = $1",
+		req.Id,
+		req.Status,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = f.insertHistory(ctx, tx, req.Id, status.String, req.Status, req.Comment)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if models.OrderReleasesStock(status.String, req.Status) {
+		err = releaseStock(ctx, tx, req.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.insertOrderEvent(ctx, tx, models.EventOrderUpdated, req.Id)
+	if err != nil {
+		return err
+	}
+
+	return wrapError(tx.Commit(ctx))
+}
+
+func (f *OrderRepo) insertHistory(ctx context.Context, tx pgx.Tx, orderId, from, to, comment string) error {
+
+	query := `
+		INSERT INTO order_status_history(
+			id,
+			order_id,
+			from_status,
+			to_status,
+			comment,
+			created_at
+		) VALUES ( $1, $2, $3, $4, $5, clock_timestamp() )
+	`
+
+	_, err := tx.Exec(ctx, query,
+		uuid.New().String(),
+		orderId,
+		helper.NewNullString(from),
+		to,
+		helper.NewNullString(comment),
+	)
+
+	return wrapError(err)
+}
+
+func (f *OrderRepo) getHistory(ctx context.Context, orderId string) ([]models.OrderStatusHistory, error) {
+
+	var history []models.OrderStatusHistory
+
+	query := `
+	SELECT
+		id,
+		from_status,
+		to_status,
+		comment,
+		created_at
+	FROM
+		order_status_history
+	WHERE order_id = $1
+	ORDER BY created_at
+	`
+
+	rows, err := f.db.Query(ctx, query, orderId)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id         sql.NullString
+			fromStatus sql.NullString
+			toStatus   sql.NullString
+			comment    sql.NullString
+			createdAt  sql.NullString
+		)
+
+		err = rows.Scan(
+			&id,
+			&fromStatus,
+			&toStatus,
+			&comment,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		history = append(history, models.OrderStatusHistory{
+			Id:         id.String,
+			FromStatus: fromStatus.String,
+			ToStatus:   toStatus.String,
+			Comment:    comment.String,
+			CreatedAt:  createdAt.String,
+		})
+	}
+
+	return history, wrapError(rows.Err())
+}
+
+// insertItems freezes the price in effect now into every line item and
+// takes the order currency from the products, which must all share one.
+func (f *OrderRepo) insertItems(ctx context.Context, tx pgx.Tx, orderId string, items []models.CreateOrderItem) error {
+
+	query := `
+		INSERT INTO order_items(
+			id,
+			order_id,
+			product_id,
+			quantity,
+			price,
+			created_at
+		)
+		SELECT $1, $2, products.id, $3, ` + productPrice + `, clock_timestamp()
+		FROM products
+		WHERE products.id = $4 AND products.deleted_at IS NULL
+	`
+
+	for i, item := range items {
+		result, err := tx.Exec(ctx, query,
+			uuid.New().String(),
+			orderId,
+			item.Quantity,
+			item.ProductID,
+		)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return storage.NewInvalidField(fmt.Sprintf("items[%d].product_id", i), "product does not exist")
+		}
+	}
+
+	var currencies []string
+
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT products.currency
+		FROM order_items
+		JOIN products ON order_items.product_id = products.id
+		WHERE order_items.order_id = $1
+	`, orderId)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency sql.NullString
+
+		err = rows.Scan(&currency)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		currencies = append(currencies, currency.String)
+	}
+
+	if err = rows.Err(); err != nil {
+		return wrapError(err)
+	}
+
+	// the handlers require items, this keeps a caller that skips them from
+	// reading past the end
+	if len(currencies) == 0 {
+		return storage.NewInvalidField("items", "must contain at least 1 items")
+	}
+
+	if len(currencies) > 1 {
+		return storage.NewInvalidField("items", "products must all have the same currency")
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE orders SET currency = $2 WHERE id = $1", orderId, currencies[0])
+
+	return wrapError(err)
+}
+
+func (f *OrderRepo) getItems(ctx context.Context, orderIds []string) (map[string][]models.OrderItem, error) {
+
+	var items = make(map[string][]models.OrderItem)
+
+	query := `
+	SELECT
+		order_items.order_id,
+		order_items.id,
+		order_items.quantity,
+		order_items.price,
+		order_items.price * order_items.quantity,
+		products.id,
+		products.name,
+		categories.id,
+		categories.name,
+		categories.parent_id
+	FROM
+		order_items
+	JOIN products ON order_items.product_id = products.id
+	JOIN categories ON products.category_id = categories.id
+	WHERE order_items.order_id = ANY($1::uuid[])
+	ORDER BY order_items.created_at
+	`
+
+	rows, err := f.db.Query(ctx, query, orderIds)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderId          sql.NullString
+			itemId           sql.NullString
+			quantity         sql.NullInt64
+			price            decimal.NullDecimal
+			subtotal         decimal.NullDecimal
+			productId        sql.NullString
+			productName      sql.NullString
+			categoryId       sql.NullString
+			categoryName     sql.NullString
+			categoryParentId sql.NullString
+		)
+
+		err = rows.Scan(
+			&orderId,
+			&itemId,
+			&quantity,
+			&price,
+			&subtotal,
+			&productId,
+			&productName,
+			&categoryId,
+			&categoryName,
+			&categoryParentId,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		items[orderId.String] = append(items[orderId.String], models.OrderItem{
+			Id: itemId.String,
+			Product: models.ProductList{
+				Id:   productId.String,
+				Name: productName.String,
+				Category: models.ProductCategory{
+					Id:       categoryId.String,
+					Name:     categoryName.String,
+					ParentID: categoryParentId.String,
+				},
+			},
+			Quantity: int(quantity.Int64),
+			Price:    price.Decimal,
+			Subtotal: subtotal.Decimal,
+		})
+	}
+
+	return items, wrapError(rows.Err())
+}
+
+// insertOrderEvent writes the order as it stands in tx into the outbox.
+func (f *OrderRepo) insertOrderEvent(ctx context.Context, tx pgx.Tx, eventType, orderId string) error {
+
+	var (
+		userId      sql.NullString
+		description sql.NullString
+		status      sql.NullString
+		currency    sql.NullString
+		version     sql.NullInt64
+		deleted     bool
+	)
+
+	err := tx.QueryRow(ctx,
+		"SELECT user_id, description, status, currency, version, deleted_at IS NOT NULL FROM orders WHERE id = $1",
+		orderId,
+	).Scan(&userId, &description, &status, &currency, &version, &deleted)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	event := models.OrderEvent{
+		Id:          orderId,
+		UserID:      userId.String,
+		Description: description.String,
+		Status:      status.String,
+		Currency:    currency.String,
+		Total:       decimal.Zero,
+		Version:     int(version.Int64),
+		Deleted:     deleted,
+	}
+
+	rows, err := tx.Query(ctx,
+		"SELECT product_id, quantity, price FROM order_items WHERE order_id = $1 ORDER BY created_at",
+		orderId,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	for rows.Next() {
+		var (
+			productId sql.NullString
+			quantity  sql.NullInt64
+			price     decimal.NullDecimal
+		)
+
+		if err = rows.Scan(&productId, &quantity, &price); err != nil {
+			rows.Close()
+			return wrapError(err)
+		}
+
+		event.Items = append(event.Items, models.OrderEventItem{
+			ProductID: productId.String,
+			Quantity:  int(quantity.Int64),
+			Price:     price.Decimal,
+		})
+		event.Total = event.Total.Add(price.Decimal.Mul(decimal.NewFromInt(quantity.Int64)))
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return wrapError(err)
+	}
+
+	return insertEvent(ctx, tx, eventType, orderId, event)
+}
+
+func orderTotal(items []models.OrderItem) decimal.Decimal {
+	var total = decimal.Zero
+
+	for _, item := range items {
+		total = total.Add(item.Subtotal)
+	}
+
+	return total
 }
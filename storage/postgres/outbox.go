@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"crud/models"
+	"crud/pkg/metrics"
+)
+
+type OutboxRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepo(db *pgxpool.Pool) *OutboxRepo {
+	return &OutboxRepo{
+		db: db,
+	}
+}
+
+// insertEvent writes a domain event into the outbox inside the transaction
+// of the change it describes, so the event exists exactly when the change
+// commits.
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType, aggregateId string, payload interface{}) error {
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox_events(
+			id,
+			event_type,
+			aggregate_id,
+			payload
+		) VALUES ( $1, $2, $3, $4 )
+	`
+
+	_, err = tx.Exec(ctx, query,
+		uuid.New().String(),
+		eventType,
+		aggregateId,
+		body,
+	)
+
+	return wrapError(err)
+}
+
+func (f *OutboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Event, error) {
+	defer metrics.ObserveQuery("outbox", "Claim", time.Now())
+
+	var resp []*models.Event
+
+	// SKIP LOCKED lets several instances claim disjoint batches, and pushing
+	// next_attempt_at out by the lease keeps a batch from being handed out
+	// twice while it is delivered
+	query := `
+		WITH claimed AS (
+			UPDATE outbox_events
+			SET next_attempt_at = now() + $2::interval
+			WHERE id IN (
+				SELECT id FROM outbox_events
+				WHERE delivered_at IS NULL AND next_attempt_at <= now()
+				ORDER BY created_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, event_type, aggregate_id, payload, created_at, attempts
+		)
+		SELECT id, event_type, aggregate_id, payload, created_at, attempts
+		FROM claimed
+		ORDER BY created_at
+	`
+
+	rows, err := f.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          sql.NullString
+			eventType   sql.NullString
+			aggregateId sql.NullString
+			payload     []byte
+			createdAt   sql.NullString
+			attempts    sql.NullInt64
+		)
+
+		err = rows.Scan(
+			&id,
+			&eventType,
+			&aggregateId,
+			&payload,
+			&createdAt,
+			&attempts,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp = append(resp, &models.Event{
+			Id:          id.String,
+			Type:        eventType.String,
+			AggregateID: aggregateId.String,
+			Payload:     payload,
+			CreatedAt:   createdAt.String,
+			Attempts:    int(attempts.Int64),
+		})
+	}
+
+	return resp, wrapError(rows.Err())
+}
+
+func (f *OutboxRepo) MarkDelivered(ctx context.Context, id string) error {
+	defer metrics.ObserveQuery("outbox", "MarkDelivered", time.Now())
+
+	_, err := f.db.Exec(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, last_error = NULL, delivered_at = now() WHERE id = $1",
+		id,
+	)
+
+	return wrapError(err)
+}
+
+func (f *OutboxRepo) MarkFailed(ctx context.Context, id string, reason string, retryAt *time.Time) error {
+	defer metrics.ObserveQuery("outbox", "MarkFailed", time.Now())
+
+	// a NULL next_attempt_at is never due again; the timestamptz cast puts
+	// retryAt in the session time zone, the one now() is stored in
+	_, err := f.db.Exec(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3::timestamptz WHERE id = $1",
+		id,
+		reason,
+		retryAt,
+	)
+
+	return wrapError(err)
+}
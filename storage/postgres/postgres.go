@@ -2,53 +2,111 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"crud/config"
+	"crud/models"
 	"crud/storage"
 )
 
 type Store struct {
 	db       *pgxpool.Pool
+	migrator *Migrator
 	category *CategoryRepo
 	product  *ProductRepo
 	order    *OrderRepo
+	user     *UserRepo
+
+	idempotency *IdempotencyRepo
+	outbox      *OutboxRepo
+	webhook     *WebhookRepo
 }
 
 func NewPostgres(ctx context.Context, cfg config.Config) (storage.StorageI, error) {
-	config, err := pgxpool.ParseConfig(fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.PostgresUser,
-		cfg.PostgresPassword,
-		cfg.PostgresHost,
-		cfg.PostgresPort,
-		cfg.PostgresDatabase,
-	))
+	pool, err := NewPool(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	config.MaxConns = cfg.PostgresMaxConnections
+	migrator, err := NewMigrator(pool)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
 
-	pool, err := pgxpool.ConnectConfig(ctx, config)
+	if cfg.MigrateOnStartup {
+		err = migrator.Up(ctx)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	err = prometheus.Register(newPoolCollector(pool))
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
 
 	return &Store{
 		db:       pool,
+		migrator: migrator,
 		category: NewCategoryRepo(pool),
 		product:  NewProductRepo(pool),
 		order:    NewOrderRepo(pool),
+		user:     NewUserRepo(pool),
+
+		idempotency: NewIdempotencyRepo(pool),
+		outbox:      NewOutboxRepo(pool),
+		webhook:     NewWebhookRepo(pool),
 	}, err
 }
 
+func NewPool(ctx context.Context, cfg config.Config) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(cfg.PostgresURL())
+	if err != nil {
+		return nil, err
+	}
+
+	config.MaxConns = cfg.PostgresMaxConnections
+	config.MinConns = cfg.PostgresMinConnections
+	config.MaxConnLifetime = cfg.PostgresMaxConnLifetime
+	config.HealthCheckPeriod = cfg.PostgresHealthCheckPeriod
+
+	return pgxpool.ConnectConfig(ctx, config)
+}
+
 func (s *Store) CloseDB() {
 	s.db.Close()
 }
 
+// Ready pings the pool and checks that the schema is migrated at least to
+// the version this binary was built with.
+func (s *Store) Ready(ctx context.Context) error {
+
+	err := s.db.Ping(ctx)
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := s.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if dirty || version < s.migrator.Latest() {
+		return fmt.Errorf("%w: schema is at version %d (dirty: %t), want %d", storage.ErrMigrationsPending, version, dirty, s.migrator.Latest())
+	}
+
+	return nil
+}
+
 func (s *Store) Category() storage.CategoryRepoI {
 
 	if s.category == nil {
@@ -75,3 +133,114 @@ func (s *Store) Order() storage.OrderRepoI {
 
 	return s.order
 }
+
+func (s *Store) User() storage.UserRepoI {
+
+	if s.user == nil {
+		s.user = NewUserRepo(s.db)
+	}
+
+	return s.user
+}
+
+func (s *Store) Idempotency() storage.IdempotencyRepoI {
+
+	if s.idempotency == nil {
+		s.idempotency = NewIdempotencyRepo(s.db)
+	}
+
+	return s.idempotency
+}
+
+func (s *Store) Outbox() storage.OutboxRepoI {
+
+	if s.outbox == nil {
+		s.outbox = NewOutboxRepo(s.db)
+	}
+
+	return s.outbox
+}
+
+func (s *Store) Webhook() storage.WebhookRepoI {
+
+	if s.webhook == nil {
+		s.webhook = NewWebhookRepo(s.db)
+	}
+
+	return s.webhook
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// liveCategory reports whether the category exists and is not deleted.
+func liveCategory(ctx context.Context, db querier, id string) (bool, error) {
+
+	var found bool
+
+	err := db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)",
+		id,
+	).Scan(&found)
+
+	return found, wrapError(err)
+}
+
+// checkVersion tells why a compare-and-swap on version matched no row: a
+// live row means it was changed in the meantime, no row that it is gone.
+func checkVersion(ctx context.Context, db querier, table, id string) error {
+
+	var version int
+
+	err := db.QueryRow(ctx, "SELECT version FROM "+table+" WHERE id = $1 AND deleted_at IS NULL", id).Scan(&version)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return storage.ErrVersionMismatch
+}
+
+// patchQuery builds the UPDATE of a merge patch from the supplied columns,
+// with the same version check and bump as the PUT queries. The named
+// parameters are left for helper.ReplaceQueryParams.
+func patchQuery(table string, set []string) string {
+
+	set = append(set, "version = version + 1", "updated_at = now()")
+
+	return "UPDATE " + table + " SET " + strings.Join(set, ", ") +
+		" WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)"
+}
+
+// patchNullString turns an explicit null into SQL NULL.
+func patchNullString(p models.Patch[string]) sql.NullString {
+	if p.Null {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: p.Value, Valid: true}
+}
+
+// keysetWhere continues a (created_at, id) ordered list after the cursor.
+func keysetWhere(table, sortOrder, createdAt, id string) string {
+	op := ">"
+	if sortOrder == models.SortDesc {
+		op = "<"
+	}
+
+	return " AND (" + table + ".created_at, " + table + ".id) " + op + " (" + createdAt + "::timestamp, " + id + "::uuid)"
+}
+
+// countRows runs the COUNT(*) of a list with the list's filters.
+func countRows(ctx context.Context, db querier, query string, args []interface{}) (int, error) {
+
+	var count int
+
+	err := db.QueryRow(ctx, query, args...).Scan(&count)
+
+	return count, wrapError(err)
+}
@@ -3,15 +3,28 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/shopspring/decimal"
 
 	"crud/models"
 	"crud/pkg/helper"
+	"crud/pkg/metrics"
+	"crud/storage"
 )
 
+// productSortColumns whitelists the columns GET /product can be sorted by.
+var productSortColumns = map[string]string{
+	models.ProductSortPrice:     productPrice,
+	models.ProductSortName:      "products.name",
+	models.ProductSortCreatedAt: "products.created_at",
+}
+
 type ProductRepo struct {
 	db *pgxpool.Pool
 }
@@ -23,43 +36,90 @@ func NewProductRepo(db *pgxpool.Pool) *ProductRepo {
 }
 
 func (f *ProductRepo) Create(ctx context.Context, product *models.CreateProduct) (string, error) {
+	defer metrics.ObserveQuery("product", "Create", time.Now())
+
+	ok, err := liveCategory(ctx, f.db, product.CategoryID)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", storage.NewInvalidField("category_id", "category does not exist")
+	}
 
 	var (
 		id    = uuid.New().String()
 		query string
 	)
 
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
 	query = `
 		INSERT INTO products(
 			id,
 			name,
 			price,
+			currency,
 			category_id,
+			stock_quantity,
 			updated_at
-		) VALUES ( $1, $2, $3, $4, now() )
+		) VALUES ( $1, $2, $3, $4, $5, $6, now() )
 	`
 
-	_, err := f.db.Exec(ctx, query,
+	_, err = tx.Exec(ctx, query,
 		id,
 		product.Name,
 		product.Price,
+		product.Currency,
 		product.CategoryID,
+		product.StockQuantity,
 	)
 
 	if err != nil {
-		return "", err
+		return "", wrapError(err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO product_prices (id, product_id, price, effective_from) VALUES ( $1, $2, $3, now() )",
+		uuid.New().String(),
+		id,
+		product.Price,
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	// the initial stock opens the ledger so it always sums up to stock_quantity
+	if product.StockQuantity > 0 {
+		err = insertMovement(ctx, tx, id, "", product.StockQuantity, models.MovementRestock, "initial stock")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", wrapError(err)
 	}
 
 	return id, nil
 }
 
 func (f *ProductRepo) GetByPKey(ctx context.Context, pkey *models.ProductPrimarKey) (*models.Product, error) {
+	defer metrics.ObserveQuery("product", "GetByPKey", time.Now())
 
 	var (
 		id          sql.NullString
 		name        sql.NullString
-		price       sql.NullFloat64
+		price       decimal.NullDecimal
+		currency    sql.NullString
 		category_id sql.NullString
+		stock       sql.NullInt64
+		version     sql.NullInt64
 		createdAt   sql.NullString
 		updatedAt   sql.NullString
 	)
@@ -68,8 +128,11 @@ func (f *ProductRepo) GetByPKey(ctx context.Context, pkey *models.ProductPrimarK
 		SELECT
 			id,
 			name,
-			price,
+			` + productPrice + `,
+			currency,
 			category_id,
+			stock_quantity,
+			version,
 			created_at,
 			updated_at
 		FROM
@@ -82,139 +145,491 @@ func (f *ProductRepo) GetByPKey(ctx context.Context, pkey *models.ProductPrimarK
 			&id,
 			&name,
 			&price,
+			&currency,
 			&category_id,
+			&stock,
+			&version,
 			&createdAt,
 			&updatedAt,
 		)
 
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return &models.Product{
-		Id:         id.String,
-		Name:       name.String,
-		Price:      price.Float64,
-		CategoryID: category_id.String,
-		CreatedAt:  createdAt.String,
-		UpdatedAt:  updatedAt.String,
+		Id:            id.String,
+		Name:          name.String,
+		Price:         price.Decimal,
+		Currency:      currency.String,
+		CategoryID:    category_id.String,
+		StockQuantity: int(stock.Int64),
+		Version:       int(version.Int64),
+		CreatedAt:     createdAt.String,
+		UpdatedAt:     updatedAt.String,
 	}, nil
 }
 
 func (f *ProductRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+	defer metrics.ObserveQuery("product", "GetList", time.Now())
 
 	var (
-		resp   = models.GetListProductResponse{}
-		offset = ""
-		limit  = ""
+		resp  = models.GetListProductResponse{}
+		where = " WHERE products.deleted_at IS NULL"
+		args  []interface{}
 	)
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
 	}
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	where += productListWhere(req, arg)
+
+	if req.IncludeCount {
+		count, err := countRows(ctx, f.db, "SELECT COUNT(*) FROM products"+where, args)
+		if err != nil {
+			return nil, err
+		}
+		resp.Count = &count
+	}
+
+	column, order := productListOrder(req)
+
+	// keyset pages follow (created_at, id), the other sorts page by offset
+	keyset := column == productSortColumns[models.ProductSortCreatedAt]
+
+	if req.Cursor != nil {
+		where += keysetWhere("products", req.SortOrder, arg(req.Cursor.CreatedAt), arg(req.Cursor.Id))
 	}
 
 	query := `
 		SELECT
-			COUNT(*) OVER(),
 			id,
 			name,
-			price,
+			` + productPrice + `,
+			currency,
 			category_id,
+			stock_quantity,
+			version,
 			created_at,
-			updated_at
+			updated_at,
+			created_at
 		FROM
 			products
-		WHERE products.deleted_at IS NULL
 	`
 
-	query += offset + limit
+	query += where + order
+
+	if req.Offset > 0 {
+		query += " OFFSET " + arg(req.Offset)
+	}
 
-	rows, err := f.db.Query(ctx, query)
+	// one extra row tells whether there is a next page
+	if req.Limit > 0 {
+		query += " LIMIT " + arg(req.Limit+1)
+	}
+
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	var last models.Cursor
 
 	for rows.Next() {
 
 		var (
 			id          sql.NullString
 			name        sql.NullString
-			price       sql.NullFloat64
+			price       decimal.NullDecimal
+			currency    sql.NullString
 			category_id sql.NullString
+			stock       sql.NullInt64
+			version     sql.NullInt64
 			createdAt   sql.NullString
 			updatedAt   sql.NullString
+			position    sql.NullTime
 		)
 
 		err := rows.Scan(
-			&resp.Count,
 			&id,
 			&name,
 			&price,
+			&currency,
 			&category_id,
+			&stock,
+			&version,
 			&createdAt,
 			&updatedAt,
+			&position,
 		)
 
 		if err != nil {
-			return nil, err
+			return nil, wrapError(err)
 		}
 
+		if req.Limit > 0 && len(resp.Products) == int(req.Limit) {
+			if keyset {
+				resp.NextCursor = helper.EncodeCursor(last)
+			}
+			break
+		}
+
+		last = models.Cursor{CreatedAt: position.Time, Id: id.String}
+
 		resp.Products = append(resp.Products, models.Product{
-			Id:         id.String,
-			Name:       name.String,
-			Price:      price.Float64,
-			CategoryID: category_id.String,
-			CreatedAt:  createdAt.String,
-			UpdatedAt:  updatedAt.String,
+			Id:            id.String,
+			Name:          name.String,
+			Price:         price.Decimal,
+			Currency:      currency.String,
+			CategoryID:    category_id.String,
+			StockQuantity: int(stock.Int64),
+			Version:       int(version.Int64),
+			CreatedAt:     createdAt.String,
+			UpdatedAt:     updatedAt.String,
 		})
 
 	}
 
-	return &resp, err
+	return &resp, wrapError(rows.Err())
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// productListWhere adds the filters of req to the products WHERE clause,
+// binding the values with arg.
+func productListWhere(req *models.GetListProductRequest, arg func(interface{}) string) string {
+
+	var where string
+
+	if req.CategoryID != "" {
+		if req.IncludeDescendants {
+			where += `
+			AND products.category_id IN (
+				WITH RECURSIVE tree AS (
+					SELECT id FROM categories WHERE id = ` + arg(req.CategoryID) + ` AND deleted_at IS NULL
+					UNION ALL
+					SELECT categories.id FROM categories
+					JOIN tree ON categories.parent_id = tree.id
+					WHERE categories.deleted_at IS NULL
+				)
+				SELECT id FROM tree
+			)`
+		} else {
+			where += " AND products.category_id = " + arg(req.CategoryID)
+		}
+	}
+
+	if req.MinPrice != nil {
+		where += " AND " + productPrice + " >= " + arg(*req.MinPrice)
+	}
+
+	if req.MaxPrice != nil {
+		where += " AND " + productPrice + " <= " + arg(*req.MaxPrice)
+	}
+
+	// the tsvector match uses products_name_tsv_idx, the ILIKE fallback
+	// catches partial words through products_name_trgm_idx; the search is
+	// escaped there so a % or _ in it matches itself
+	if req.Search != "" {
+		where += " AND (to_tsvector('simple', products.name) @@ plainto_tsquery('simple', " + arg(req.Search) + ")" +
+			" OR products.name ILIKE '%' || " + arg(likeEscaper.Replace(req.Search)) + ` || '%' ESCAPE '\')`
+	}
+
+	return where
+}
+
+// productListOrder returns the sort column of req and its ORDER BY clause.
+func productListOrder(req *models.GetListProductRequest) (string, string) {
+
+	column, ok := productSortColumns[req.SortBy]
+	if !ok {
+		column = productSortColumns[models.ProductSortCreatedAt]
+	}
+
+	if req.SortOrder == models.SortDesc {
+		return column, " ORDER BY " + column + " DESC, products.id DESC"
+	}
+
+	return column, " ORDER BY " + column + " ASC, products.id ASC"
 }
 
 func (f *ProductRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	defer metrics.ObserveQuery("product", "Update", time.Now())
+
+	ok, err := liveCategory(ctx, f.db, req.CategoryID)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return 0, storage.NewInvalidField("category_id", "category does not exist")
+	}
 
 	var (
 		query  = ""
 		params map[string]interface{}
+		price  decimal.NullDecimal
 	)
 
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
 	query = `
 		UPDATE
 			products
 		SET
 			name = :name,
-			price = :price,
+			currency = COALESCE(NULLIF(:currency, ''), currency),
 			category_id = :category_id,
+			version = version + 1,
 			updated_at = now()
-		WHERE id = :id
-	`
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
+		RETURNING ` + productPrice
 
 	params = map[string]interface{}{
 		"id":          req.Id,
 		"name":        req.Name,
-		"price":       req.Price,
+		"currency":    req.Currency,
 		"category_id": req.CategoryID,
+		"version":     req.Version,
 	}
 
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	rowsAffected, err := f.db.Exec(ctx, query, args...)
+	err = tx.QueryRow(ctx, query, args...).Scan(&price)
+	if err == pgx.ErrNoRows && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "products", req.Id)
+	}
+
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+
 	if err != nil {
-		return 0, err
+		return 0, wrapError(err)
+	}
+
+	// a new price takes effect now, the scheduled changes stay in place
+	if !price.Decimal.Equal(req.Price) {
+		err = schedulePrice(ctx, tx, req.Id, req.Price, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, wrapError(err)
 	}
 
-	return rowsAffected.RowsAffected(), nil
+	return 1, nil
 }
+func (f *ProductRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+	defer metrics.ObserveQuery("product", "Patch", time.Now())
 
-func (f *ProductRepo) Delete(ctx context.Context, req *models.ProductPrimarKey) error {
+	var (
+		set    []string
+		price  decimal.NullDecimal
+		params = map[string]interface{}{
+			"id":      req.Id,
+			"version": req.Version,
+		}
+	)
+
+	if req.CategoryID.Set {
+		ok, err := liveCategory(ctx, f.db, req.CategoryID.Value)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			return 0, storage.NewInvalidField("category_id", "category does not exist")
+		}
+
+		set = append(set, "category_id = :category_id")
+		params["category_id"] = req.CategoryID.Value
+	}
+
+	if req.Name.Set {
+		set = append(set, "name = :name")
+		params["name"] = req.Name.Value
+	}
+
+	if req.Currency.Set {
+		set = append(set, "currency = :currency")
+		params["currency"] = req.Currency.Value
+	}
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args := helper.ReplaceQueryParams(patchQuery("products", set)+" RETURNING "+productPrice, params)
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&price)
+	if err == pgx.ErrNoRows && req.Version > 0 {
+		return 0, checkVersion(ctx, tx, "products", req.Id)
+	}
+
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	// a new price takes effect now, the scheduled changes stay in place
+	if req.Price.Set && !price.Decimal.Equal(req.Price.Value) {
+		err = schedulePrice(ctx, tx, req.Id, req.Price.Value, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return 1, nil
+}
+
+func (f *ProductRepo) Delete(ctx context.Context, req *models.DeleteProduct) error {
+	defer metrics.ObserveQuery("product", "Delete", time.Now())
+
+	result, err := f.db.Exec(ctx,
+		"UPDATE products SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)",
+		req.Id,
+		req.Version,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if result.RowsAffected() == 0 && req.Version > 0 {
+		return checkVersion(ctx, f.db, "products", req.Id)
+	}
+
+	return nil
+}
+
+func (f *ProductRepo) Restock(ctx context.Context, req *models.Restock) error {
+	defer metrics.ObserveQuery("product", "Restock", time.Now())
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx,
+		"UPDATE products SET stock_quantity = stock_quantity + $2, version = version + 1, updated_at = now() WHERE id = $1 AND deleted_at IS NULL",
+		req.ProductID,
+		req.Quantity,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
 
-	_, err := f.db.Exec(ctx, "UPDATE products SET deleted_at = now() WHERE id = $1", req.Id)
+	err = insertMovement(ctx, tx, req.ProductID, "", req.Quantity, models.MovementRestock, req.Comment)
 	if err != nil {
 		return err
 	}
 
-	return err
+	return wrapError(tx.Commit(ctx))
+}
+
+func (f *ProductRepo) GetMovements(ctx context.Context, req *models.GetListMovementRequest) (*models.GetListMovementResponse, error) {
+	defer metrics.ObserveQuery("product", "GetMovements", time.Now())
+
+	var (
+		resp = models.GetListMovementResponse{}
+		args = []interface{}{req.ProductID}
+	)
+
+	query := `
+		SELECT
+			COUNT(*) OVER(),
+			id,
+			product_id,
+			order_id,
+			quantity,
+			reason,
+			comment,
+			created_at
+		FROM
+			inventory_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC
+	`
+
+	if req.Offset > 0 {
+		args = append(args, req.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	if req.Limit > 0 {
+		args = append(args, req.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id        sql.NullString
+			productId sql.NullString
+			orderId   sql.NullString
+			quantity  sql.NullInt64
+			reason    sql.NullString
+			comment   sql.NullString
+			createdAt sql.NullString
+		)
+
+		err := rows.Scan(
+			&resp.Count,
+			&id,
+			&productId,
+			&orderId,
+			&quantity,
+			&reason,
+			&comment,
+			&createdAt,
+		)
+
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp.Movements = append(resp.Movements, models.InventoryMovement{
+			Id:        id.String,
+			ProductID: productId.String,
+			OrderID:   orderId.String,
+			Quantity:  int(quantity.Int64),
+			Reason:    reason.String,
+			Comment:   comment.String,
+			CreatedAt: createdAt.String,
+		})
+	}
+
+	return &resp, wrapError(rows.Err())
 }
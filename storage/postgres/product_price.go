@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+
+	"crud/models"
+	"crud/pkg/metrics"
+)
+
+// productPrice resolves the price in effect now from product_prices. The
+// periods are contiguous, so the latest one that has started is current;
+// products.price only covers rows without a schedule.
+const productPrice = `COALESCE((
+	SELECT product_prices.price FROM product_prices
+	WHERE product_prices.product_id = products.id AND product_prices.effective_from <= now()
+	ORDER BY product_prices.effective_from DESC
+	LIMIT 1
+), products.price)`
+
+func (f *ProductRepo) SchedulePrice(ctx context.Context, req *models.SchedulePrice) error {
+	defer metrics.ObserveQuery("product", "SchedulePrice", time.Now())
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = schedulePrice(ctx, tx, req.ProductID, req.Price, &req.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+
+	return wrapError(tx.Commit(ctx))
+}
+
+func (f *ProductRepo) GetPrices(ctx context.Context, pkey *models.ProductPrimarKey) (*models.ProductPriceHistory, error) {
+	defer metrics.ObserveQuery("product", "GetPrices", time.Now())
+
+	var resp = &models.ProductPriceHistory{Prices: []models.ProductPrice{}}
+
+	query := `
+		SELECT
+			id,
+			product_id,
+			price,
+			effective_from,
+			effective_to,
+			created_at
+		FROM
+			product_prices
+		WHERE product_id = $1
+		ORDER BY effective_from
+	`
+
+	rows, err := f.db.Query(ctx, query, pkey.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id            sql.NullString
+			productId     sql.NullString
+			price         decimal.NullDecimal
+			effectiveFrom sql.NullString
+			effectiveTo   sql.NullString
+			createdAt     sql.NullString
+		)
+
+		err = rows.Scan(
+			&id,
+			&productId,
+			&price,
+			&effectiveFrom,
+			&effectiveTo,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp.Prices = append(resp.Prices, models.ProductPrice{
+			Id:            id.String,
+			ProductID:     productId.String,
+			Price:         price.Decimal,
+			EffectiveFrom: effectiveFrom.String,
+			EffectiveTo:   effectiveTo.String,
+			CreatedAt:     createdAt.String,
+		})
+	}
+
+	return resp, wrapError(rows.Err())
+}
+
+// schedulePrice inserts a change point into the product's schedule: the
+// period that contains it is cut there and the new price runs until the
+// next scheduled change. A nil from means now.
+func schedulePrice(ctx context.Context, tx pgx.Tx, productId string, price decimal.Decimal, from *time.Time) error {
+
+	var (
+		at          time.Time
+		effective   time.Time
+		currency    sql.NullString
+		periodId    sql.NullString
+		periodStart sql.NullTime
+		periodEnd   sql.NullTime
+	)
+
+	// lock the product so concurrent changes cut the schedule one at a time
+	err := tx.QueryRow(ctx,
+		"SELECT COALESCE($2::timestamptz, now())::timestamp, COALESCE($2::timestamptz, now()), currency FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		productId,
+		from,
+	).Scan(&at, &effective, &currency)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT id, effective_from, effective_to
+		FROM product_prices
+		WHERE product_id = $1 AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+	`, productId, at).Scan(&periodId, &periodStart, &periodEnd)
+
+	switch {
+	case err == pgx.ErrNoRows:
+		// the change comes before the first period, it runs until that one
+		err = tx.QueryRow(ctx,
+			"SELECT MIN(effective_from) FROM product_prices WHERE product_id = $1",
+			productId,
+		).Scan(&periodEnd)
+		if err != nil {
+			return wrapError(err)
+		}
+
+	case err != nil:
+		return wrapError(err)
+
+	case periodStart.Time.Equal(at):
+		_, err = tx.Exec(ctx, "UPDATE product_prices SET price = $2 WHERE id = $1", periodId.String, price)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		return insertPriceEvent(ctx, tx, productId, price, currency.String, effective, from != nil)
+
+	default:
+		_, err = tx.Exec(ctx, "UPDATE product_prices SET effective_to = $2 WHERE id = $1", periodId.String, at)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	query := `
+		INSERT INTO product_prices(
+			id,
+			product_id,
+			price,
+			effective_from,
+			effective_to
+		) VALUES ( $1, $2, $3, $4, $5 )
+	`
+
+	_, err = tx.Exec(ctx, query,
+		uuid.New().String(),
+		productId,
+		price,
+		at,
+		periodEnd,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	// an immediate change also moves the list price, so the products row
+	// tells the same as the schedule for now
+	if from == nil {
+		_, err = tx.Exec(ctx, "UPDATE products SET price = $2, version = version + 1, updated_at = now() WHERE id = $1", productId, price)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	return insertPriceEvent(ctx, tx, productId, price, currency.String, effective, from != nil)
+}
+
+// insertPriceEvent emits product.price_changed as the change is made; a
+// scheduled change only takes effect at from.
+func insertPriceEvent(ctx context.Context, tx pgx.Tx, productId string, price decimal.Decimal, currency string, from time.Time, scheduled bool) error {
+	return insertEvent(ctx, tx, models.EventProductPriceChanged, productId, models.ProductPriceEvent{
+		ProductID:     productId,
+		Price:         price,
+		Currency:      currency,
+		EffectiveFrom: from.UTC().Format(time.RFC3339Nano),
+		Scheduled:     scheduled && from.After(time.Now()),
+	})
+}
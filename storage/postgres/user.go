@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"crud/models"
+	"crud/pkg/metrics"
+)
+
+type UserRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRepo(db *pgxpool.Pool) *UserRepo {
+	return &UserRepo{
+		db: db,
+	}
+}
+
+func (f *UserRepo) Create(ctx context.Context, user *models.CreateUser) (string, error) {
+	defer metrics.ObserveQuery("user", "Create", time.Now())
+
+	var (
+		id    = uuid.New().String()
+		query string
+	)
+
+	query = `
+		INSERT INTO users(
+			id,
+			login,
+			password,
+			role,
+			updated_at
+		) VALUES ( $1, $2, $3, $4, now() )
+	`
+
+	_, err := f.db.Exec(ctx, query,
+		id,
+		user.Login,
+		user.Password,
+		user.Role,
+	)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return id, nil
+}
+
+func (f *UserRepo) GetByPKey(ctx context.Context, pkey *models.UserPrimaryKey) (*models.User, error) {
+	defer metrics.ObserveQuery("user", "GetByPKey", time.Now())
+
+	var (
+		id        sql.NullString
+		login     sql.NullString
+		password  sql.NullString
+		role      sql.NullString
+		createdAt sql.NullString
+		updatedAt sql.NullString
+
+		where = " WHERE deleted_at IS NULL AND id = $1"
+		arg   = pkey.Id
+	)
+
+	if pkey.Login != "" {
+		where = " WHERE deleted_at IS NULL AND login = $1"
+		arg = pkey.Login
+	}
+
+	query := `
+		SELECT
+			id,
+			login,
+			password,
+			role,
+			created_at,
+			updated_at
+		FROM
+			users
+	`
+
+	err := f.db.QueryRow(ctx, query+where, arg).Scan(
+		&id,
+		&login,
+		&password,
+		&role,
+		&createdAt,
+		&updatedAt,
+	)
+
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &models.User{
+		Id:        id.String,
+		Login:     login.String,
+		Password:  password.String,
+		Role:      role.String,
+		CreatedAt: createdAt.String,
+		UpdatedAt: updatedAt.String,
+	}, nil
+}
@@ -0,0 +1,571 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"crud/models"
+	"crud/pkg/helper"
+	"crud/pkg/metrics"
+	"crud/storage"
+)
+
+type WebhookRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewWebhookRepo(db *pgxpool.Pool) *WebhookRepo {
+	return &WebhookRepo{
+		db: db,
+	}
+}
+
+func (f *WebhookRepo) Create(ctx context.Context, req *models.CreateWebhook) (string, error) {
+	defer metrics.ObserveQuery("webhook", "Create", time.Now())
+
+	var (
+		id    = uuid.New().String()
+		query string
+	)
+
+	query = `
+		INSERT INTO webhooks(
+			id,
+			user_id,
+			url,
+			events,
+			secret,
+			owner_only,
+			updated_at
+		) VALUES ( $1, $2, $3, $4, $5, $6, now() )
+	`
+
+	_, err := f.db.Exec(ctx, query,
+		id,
+		req.UserID,
+		req.URL,
+		req.Events,
+		req.Secret,
+		req.OwnerOnly,
+	)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return id, nil
+}
+
+func (f *WebhookRepo) GetByPKey(ctx context.Context, pkey *models.WebhookPrimaryKey) (*models.Webhook, error) {
+	defer metrics.ObserveQuery("webhook", "GetByPKey", time.Now())
+
+	query := `
+		SELECT
+			id,
+			user_id,
+			url,
+			events,
+			owner_only,
+			active,
+			failure_count,
+			disabled_at,
+			created_at,
+			updated_at
+		FROM
+			webhooks
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = '' OR user_id::text = $2)
+	`
+
+	resp, err := scanWebhook(f.db.QueryRow(ctx, query, pkey.Id, pkey.UserID))
+	if err != nil {
+		return &models.Webhook{}, wrapError(err)
+	}
+
+	return resp, nil
+}
+
+func (f *WebhookRepo) GetList(ctx context.Context, req *models.GetListWebhookRequest) (*models.GetListWebhookResponse, error) {
+	defer metrics.ObserveQuery("webhook", "GetList", time.Now())
+
+	var (
+		resp = &models.GetListWebhookResponse{}
+		args = []interface{}{req.UserID}
+	)
+
+	query := `
+		SELECT
+			COUNT(*) OVER(),
+			id,
+			user_id,
+			url,
+			events,
+			owner_only,
+			active,
+			failure_count,
+			disabled_at,
+			created_at,
+			updated_at
+		FROM
+			webhooks
+		WHERE deleted_at IS NULL AND ($1 = '' OR user_id::text = $1)
+		ORDER BY created_at, id
+	`
+
+	if req.Offset > 0 {
+		args = append(args, req.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	if req.Limit > 0 {
+		args = append(args, req.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count int
+
+		webhook, err := scanWebhook(rows, &count)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp.Count = count
+		resp.Webhooks = append(resp.Webhooks, webhook)
+	}
+
+	return resp, wrapError(rows.Err())
+}
+
+// scanWebhook reads the webhook columns in the order GetByPKey and GetList
+// select them, after any leading columns given in dest.
+func scanWebhook(row pgx.Row, dest ...interface{}) (*models.Webhook, error) {
+
+	var (
+		id           sql.NullString
+		userId       sql.NullString
+		url          sql.NullString
+		events       []string
+		ownerOnly    bool
+		active       bool
+		failureCount sql.NullInt64
+		disabledAt   sql.NullString
+		createdAt    sql.NullString
+		updatedAt    sql.NullString
+	)
+
+	err := row.Scan(append(dest,
+		&id,
+		&userId,
+		&url,
+		&events,
+		&ownerOnly,
+		&active,
+		&failureCount,
+		&disabledAt,
+		&createdAt,
+		&updatedAt,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Webhook{
+		Id:           id.String,
+		UserID:       userId.String,
+		URL:          url.String,
+		Events:       events,
+		OwnerOnly:    ownerOnly,
+		Active:       active,
+		FailureCount: int(failureCount.Int64),
+		DisabledAt:   disabledAt.String,
+		CreatedAt:    createdAt.String,
+		UpdatedAt:    updatedAt.String,
+	}, nil
+}
+
+func (f *WebhookRepo) Update(ctx context.Context, req *models.UpdateWebhook) (int64, error) {
+	defer metrics.ObserveQuery("webhook", "Update", time.Now())
+
+	var (
+		query  = ""
+		params map[string]interface{}
+	)
+
+	// turning a webhook back on forgets the failures that disabled it
+	query = `
+		UPDATE
+			webhooks
+		SET
+			url = :url,
+			events = :events,
+			secret = COALESCE(NULLIF(:secret, ''), secret),
+			active = COALESCE(:active, active),
+			failure_count = CASE WHEN :active THEN 0 ELSE failure_count END,
+			disabled_at = CASE WHEN :active THEN NULL ELSE disabled_at END,
+			updated_at = now()
+		WHERE id = :id AND deleted_at IS NULL AND (:user_id = '' OR user_id::text = :user_id)
+	`
+
+	params = map[string]interface{}{
+		"id":      req.Id,
+		"user_id": req.UserID,
+		"url":     req.URL,
+		"events":  req.Events,
+		"secret":  req.Secret,
+		"active":  req.Active,
+	}
+
+	query, args := helper.ReplaceQueryParams(query, params)
+
+	result, err := f.db.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (f *WebhookRepo) Delete(ctx context.Context, req *models.WebhookPrimaryKey) error {
+	defer metrics.ObserveQuery("webhook", "Delete", time.Now())
+
+	result, err := f.db.Exec(ctx,
+		"UPDATE webhooks SET active = false, deleted_at = now() WHERE id = $1 AND deleted_at IS NULL AND ($2 = '' OR user_id::text = $2)",
+		req.Id,
+		req.UserID,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (f *WebhookRepo) GetDeliveries(ctx context.Context, req *models.GetListWebhookDeliveryRequest) (*models.GetListWebhookDeliveryResponse, error) {
+	defer metrics.ObserveQuery("webhook", "GetDeliveries", time.Now())
+
+	var (
+		resp = &models.GetListWebhookDeliveryResponse{}
+		args = []interface{}{req.WebhookID}
+	)
+
+	query := `
+		SELECT
+			COUNT(*) OVER(),
+			id,
+			webhook_id,
+			event_id,
+			event_type,
+			payload,
+			attempts,
+			status_code,
+			last_error,
+			created_at,
+			next_attempt_at,
+			delivered_at
+		FROM
+			webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+	`
+
+	if req.Offset > 0 {
+		args = append(args, req.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	if req.Limit > 0 {
+		args = append(args, req.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			id          sql.NullString
+			webhookId   sql.NullString
+			eventId     sql.NullString
+			eventType   sql.NullString
+			payload     []byte
+			attempts    sql.NullInt64
+			statusCode  sql.NullInt64
+			lastError   sql.NullString
+			createdAt   sql.NullString
+			nextAttempt sql.NullString
+			deliveredAt sql.NullString
+		)
+
+		err := rows.Scan(
+			&resp.Count,
+			&id,
+			&webhookId,
+			&eventId,
+			&eventType,
+			&payload,
+			&attempts,
+			&statusCode,
+			&lastError,
+			&createdAt,
+			&nextAttempt,
+			&deliveredAt,
+		)
+
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp.Deliveries = append(resp.Deliveries, &models.WebhookDelivery{
+			Id:          id.String,
+			WebhookID:   webhookId.String,
+			EventID:     eventId.String,
+			EventType:   eventType.String,
+			Payload:     payload,
+			Attempts:    int(attempts.Int64),
+			StatusCode:  int(statusCode.Int64),
+			LastError:   lastError.String,
+			CreatedAt:   createdAt.String,
+			NextAttempt: nextAttempt.String,
+			DeliveredAt: deliveredAt.String,
+		})
+	}
+
+	return resp, wrapError(rows.Err())
+}
+
+func (f *WebhookRepo) Enqueue(ctx context.Context, req *models.WebhookEvent) (int, error) {
+	defer metrics.ObserveQuery("webhook", "Enqueue", time.Now())
+
+	body, err := json.Marshal(req.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	// owner_only webhooks get the order events of their owner's orders only
+	query := `
+		SELECT id FROM webhooks
+		WHERE deleted_at IS NULL AND active AND $1 = ANY(events)
+			AND (NOT $2 OR NOT owner_only OR user_id::text = $3)
+	`
+
+	rows, err := f.db.Query(ctx, query, req.Event.Type, req.IsOrder, req.OrderUserID)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	var ids []string
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, wrapError(err)
+		}
+
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, wrapError(err)
+	}
+
+	var queued int
+
+	// the outbox may hand the same event over again, the unique
+	// (webhook_id, event_id) keeps it to one delivery per webhook
+	for _, id := range ids {
+		result, err := f.db.Exec(ctx, `
+			INSERT INTO webhook_deliveries(
+				id,
+				webhook_id,
+				event_id,
+				event_type,
+				payload
+			) VALUES ( $1, $2, $3, $4, $5 )
+			ON CONFLICT (webhook_id, event_id) DO NOTHING
+		`,
+			uuid.New().String(),
+			id,
+			req.Event.Id,
+			req.Event.Type,
+			body,
+		)
+		if err != nil {
+			return queued, wrapError(err)
+		}
+
+		queued += int(result.RowsAffected())
+	}
+
+	return queued, nil
+}
+
+func (f *WebhookRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("webhook", "ClaimDeliveries", time.Now())
+
+	var resp []*models.WebhookDelivery
+
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = now() + $2::interval
+			WHERE id IN (
+				SELECT webhook_deliveries.id FROM webhook_deliveries
+				JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+				WHERE webhook_deliveries.delivered_at IS NULL
+					AND webhook_deliveries.next_attempt_at <= now()
+					AND webhooks.active AND webhooks.deleted_at IS NULL
+				ORDER BY webhook_deliveries.created_at
+				LIMIT $1
+				FOR UPDATE OF webhook_deliveries SKIP LOCKED
+			)
+			RETURNING id, webhook_id, event_id, event_type, payload, attempts, created_at
+		)
+		SELECT
+			claimed.id,
+			claimed.webhook_id,
+			claimed.event_id,
+			claimed.event_type,
+			claimed.payload,
+			claimed.attempts,
+			claimed.created_at,
+			webhooks.url,
+			webhooks.secret
+		FROM claimed
+		JOIN webhooks ON webhooks.id = claimed.webhook_id
+		ORDER BY claimed.created_at
+	`
+
+	rows, err := f.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        sql.NullString
+			webhookId sql.NullString
+			eventId   sql.NullString
+			eventType sql.NullString
+			payload   []byte
+			attempts  sql.NullInt64
+			createdAt sql.NullString
+			url       sql.NullString
+			secret    sql.NullString
+		)
+
+		err = rows.Scan(
+			&id,
+			&webhookId,
+			&eventId,
+			&eventType,
+			&payload,
+			&attempts,
+			&createdAt,
+			&url,
+			&secret,
+		)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		resp = append(resp, &models.WebhookDelivery{
+			Id:        id.String,
+			WebhookID: webhookId.String,
+			EventID:   eventId.String,
+			EventType: eventType.String,
+			Payload:   payload,
+			Attempts:  int(attempts.Int64),
+			CreatedAt: createdAt.String,
+			URL:       url.String,
+			Secret:    secret.String,
+		})
+	}
+
+	return resp, wrapError(rows.Err())
+}
+
+func (f *WebhookRepo) RecordAttempt(ctx context.Context, req *models.WebhookAttempt) (bool, error) {
+	defer metrics.ObserveQuery("webhook", "RecordAttempt", time.Now())
+
+	tx, err := f.db.Begin(ctx)
+	if err != nil {
+		return false, wrapError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		statusCode = sql.NullInt64{Int64: int64(req.StatusCode), Valid: req.StatusCode > 0}
+		lastError  = sql.NullString{String: req.Error, Valid: req.Error != ""}
+		disabled   bool
+	)
+
+	if req.Delivered {
+		_, err = tx.Exec(ctx,
+			"UPDATE webhook_deliveries SET attempts = attempts + 1, status_code = $2, last_error = NULL, next_attempt_at = NULL, delivered_at = now() WHERE id = $1",
+			req.DeliveryID,
+			statusCode,
+		)
+		if err != nil {
+			return false, wrapError(err)
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE webhooks SET failure_count = 0 WHERE id = $1", req.WebhookID)
+		if err != nil {
+			return false, wrapError(err)
+		}
+
+		return false, wrapError(tx.Commit(ctx))
+	}
+
+	// a NULL next_attempt_at is never due again; the timestamptz cast puts
+	// RetryAt in the session time zone, the one now() is stored in
+	_, err = tx.Exec(ctx,
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, status_code = $2, last_error = $3, next_attempt_at = $4::timestamptz WHERE id = $1",
+		req.DeliveryID,
+		statusCode,
+		lastError,
+		req.RetryAt,
+	)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE webhooks SET
+			failure_count = failure_count + 1,
+			active = active AND failure_count + 1 < $2,
+			disabled_at = CASE WHEN active AND failure_count + 1 >= $2 THEN now() ELSE disabled_at END
+		WHERE id = $1
+		RETURNING disabled_at IS NOT NULL AND disabled_at = now()
+	`, req.WebhookID, req.DisableAfter).Scan(&disabled)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	return disabled, wrapError(tx.Commit(ctx))
+}
@@ -2,15 +2,22 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"crud/models"
 )
 
 type StorageI interface {
 	CloseDB()
+	// Ready reports whether the backend can serve requests
+	Ready(ctx context.Context) error
 	Category() CategoryRepoI
 	Product() ProductRepoI
 	Order() OrderRepoI
+	User() UserRepoI
+	Idempotency() IdempotencyRepoI
+	Outbox() OutboxRepoI
+	Webhook() WebhookRepoI
 }
 
 type CategoryRepoI interface {
@@ -18,7 +25,13 @@ type CategoryRepoI interface {
 	GetByPKey(ctx context.Context, req *models.CategoryPrimaryKey) (*models.CategoryList, error)
 	GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error)
 	Update(ctx context.Context, req *models.UpdateCategory) (int64, error)
-	Delete(ctx context.Context, req *models.CategoryPrimaryKey) error
+	Patch(ctx context.Context, req *models.PatchCategory) (int64, error)
+	Delete(ctx context.Context, req *models.DeleteCategory) error
+	GetTree(ctx context.Context, req *models.GetCategoryTreeRequest) ([]*models.CategoryTree, error)
+	GetPath(ctx context.Context, req *models.CategoryPrimaryKey) (*models.CategoryPath, error)
+	// Import writes all the rows in one transaction, or none of them when a
+	// row has an error or on a dry run
+	Import(ctx context.Context, req *models.ImportCategories) (*models.ImportResult, error)
 }
 
 type ProductRepoI interface {
@@ -26,7 +39,17 @@ type ProductRepoI interface {
 	GetByPKey(ctx context.Context, req *models.ProductPrimarKey) (*models.Product, error)
 	GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error)
 	Update(ctx context.Context, req *models.UpdateProduct) (int64, error)
-	Delete(ctx context.Context, req *models.ProductPrimarKey) error
+	Patch(ctx context.Context, req *models.PatchProduct) (int64, error)
+	Delete(ctx context.Context, req *models.DeleteProduct) error
+	Restock(ctx context.Context, req *models.Restock) error
+	GetMovements(ctx context.Context, req *models.GetListMovementRequest) (*models.GetListMovementResponse, error)
+	SchedulePrice(ctx context.Context, req *models.SchedulePrice) error
+	GetPrices(ctx context.Context, req *models.ProductPrimarKey) (*models.ProductPriceHistory, error)
+	Import(ctx context.Context, req *models.ImportProducts) (*models.ImportResult, error)
+	// Export calls fn with every product matching the filters of req, in
+	// the list order; the paging fields of req are ignored. It stops at
+	// the first error of fn.
+	Export(ctx context.Context, req *models.GetListProductRequest, fn func(*models.ProductExportRow) error) error
 }
 
 type OrderRepoI interface {
@@ -34,5 +57,60 @@ type OrderRepoI interface {
 	GetByPKey(ctx context.Context, req *models.OrderPrimarKey) (*models.OrderList, error)
 	GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error)
 	Update(ctx context.Context, req *models.UpdateOrder) (int64, error)
-	Delete(ctx context.Context, req *models.OrderPrimarKey) error
+	Patch(ctx context.Context, req *models.PatchOrder) (int64, error)
+	Delete(ctx context.Context, req *models.DeleteOrder) error
+	Transition(ctx context.Context, req *models.OrderTransition) error
+	// Export calls fn with every item of the orders matching the filters of
+	// req, in the list order; the paging fields of req are ignored. It stops
+	// at the first error of fn.
+	Export(ctx context.Context, req *models.GetListOrderRequest, fn func(*models.OrderExportRow) error) error
+}
+
+type UserRepoI interface {
+	Create(ctx context.Context, req *models.CreateUser) (string, error)
+	GetByPKey(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error)
+}
+
+type IdempotencyRepoI interface {
+	// Reserve claims the key for a new request. It returns the stored record
+	// instead when the key is already taken and not expired.
+	Reserve(ctx context.Context, req *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// Complete stores the response of a reserved key.
+	Complete(ctx context.Context, req *models.IdempotencyKey) error
+	// Release drops a reserved key, so the request can be retried.
+	Release(ctx context.Context, req *models.IdempotencyKey) error
+	// PurgeExpired drops the expired keys and returns how many it dropped.
+	// Reserve already ignores them, this only keeps the table small.
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// OutboxRepoI hands the domain events the repositories write along with
+// their changes to the dispatcher.
+type OutboxRepoI interface {
+	// Claim returns up to limit events that are due, in the order they were
+	// written, and hides them from other claims for lease.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Event, error)
+	// MarkDelivered takes the event out of the outbox.
+	MarkDelivered(ctx context.Context, id string) error
+	// MarkFailed records a failed delivery. A nil retryAt gives up on the
+	// event.
+	MarkFailed(ctx context.Context, id string, reason string, retryAt *time.Time) error
+}
+
+type WebhookRepoI interface {
+	Create(ctx context.Context, req *models.CreateWebhook) (string, error)
+	GetByPKey(ctx context.Context, req *models.WebhookPrimaryKey) (*models.Webhook, error)
+	GetList(ctx context.Context, req *models.GetListWebhookRequest) (*models.GetListWebhookResponse, error)
+	Update(ctx context.Context, req *models.UpdateWebhook) (int64, error)
+	Delete(ctx context.Context, req *models.WebhookPrimaryKey) error
+	GetDeliveries(ctx context.Context, req *models.GetListWebhookDeliveryRequest) (*models.GetListWebhookDeliveryResponse, error)
+	// Enqueue queues the event for every active webhook subscribed to it and
+	// returns how many it queued. Enqueueing an event twice is a no-op.
+	Enqueue(ctx context.Context, req *models.WebhookEvent) (int, error)
+	// ClaimDeliveries returns up to limit due deliveries of active webhooks
+	// and hides them from other claims for lease.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	// RecordAttempt stores the outcome of an attempt and reports whether it
+	// disabled the webhook.
+	RecordAttempt(ctx context.Context, req *models.WebhookAttempt) (bool, error)
 }